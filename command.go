@@ -0,0 +1,213 @@
+package main
+
+import (
+	"DockerST/task"
+	"DockerST/utils"
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// 执行子命令，返回退出码
+func runCommand(args []string) int {
+	switch args[0] {
+	case "history":
+		return historyCommand(args[1:])
+	case "compare":
+		return compareCommand(args[1:])
+	case "restore":
+		return restoreCommand(args[1:])
+	default:
+		fmt.Printf(utils.T("[错误] 未知的命令：%s\n"), args[0])
+		fmt.Println(utils.T("可用命令：history、compare、restore"))
+		return 1
+	}
+}
+
+// 记录本次运行结果到历史记录
+func saveHistory(pingData utils.PingDelaySet, speedData utils.DownloadSpeedSet) {
+	if utils.NoHistory {
+		return
+	}
+	if err := utils.AppendHistory(utils.NewHistoryRun(pingData, speedData, task.FailedIPs)); err != nil {
+		fmt.Println(utils.T("\n[错误] 记录历史失败："), err)
+	}
+}
+
+func historyCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(utils.T("用法："))
+		fmt.Println(utils.T("  DockerST history list [-n 数量]        列出最近的运行记录"))
+		fmt.Println(utils.T("  DockerST history show <IP>            查看 IP 的历史趋势"))
+		fmt.Println(utils.T("  DockerST history prune [-days 天数] [-keep 数量]  清理历史记录"))
+		return 1
+	}
+	runs, err := utils.LoadHistory()
+	if err != nil {
+		fmt.Println(utils.T("[错误] 读取历史记录失败："), err)
+		return 1
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("history list", flag.ExitOnError)
+		num := fs.Int("n", 20, utils.T("显示数量"))
+		_ = fs.Parse(args[1:])
+		if *num > 0 && len(runs) > *num {
+			runs = runs[len(runs)-*num:]
+		}
+		fmt.Printf("%-26s%-22s%-8s%-8s%-8s%-18s\n", "ID", utils.T("时间"), utils.T("可用"), utils.T("失败"), utils.T("下载"), utils.T("最优 IP"))
+		for _, run := range runs {
+			fmt.Printf("%-26s%-22s%-8d%-8d%-8d%-18s\n", run.ID, run.Time.Format("2006-01-02 15:04:05"),
+				len(run.Results), len(run.Failed), len(run.Tested), run.Best)
+		}
+	case "show":
+		if len(args) < 2 {
+			fmt.Println(utils.T("[错误] 请指定 IP，例如：DockerST history show 104.16.1.1"))
+			return 1
+		}
+		points := utils.IPTrend(runs, args[1])
+		if len(points) == 0 {
+			fmt.Printf(utils.T("[信息] 历史记录中没有 IP %s 的数据。\n"), args[1])
+			return 0
+		}
+		fmt.Printf("%-22s%-10s%-10s%-12s%-16s\n", utils.T("时间"), utils.T("状态"), utils.T("丢包率"), utils.T("平均延迟"), utils.T("下载速度 (MB/s)"))
+		for _, point := range points {
+			t := point.Time.Format("2006-01-02 15:04:05")
+			if point.Failed {
+				fmt.Printf("%-22s%-10s\n", t, utils.T("不可用"))
+				continue
+			}
+			speed := "-"
+			if point.Tested {
+				speed = fmt.Sprintf("%.2f", point.Result.DownloadMBps)
+			}
+			fmt.Printf("%-22s%-10s%-10.2f%-12.2f%-16s\n", t, utils.T("可用"), point.Result.LossRate, point.Result.DelayMs, speed)
+		}
+	case "prune":
+		fs := flag.NewFlagSet("history prune", flag.ExitOnError)
+		days := fs.Int("days", 30, utils.T("删除多少天以前的记录（0 为不按时间删除）"))
+		keep := fs.Int("keep", 0, utils.T("最多保留的记录数量（0 为不限制）"))
+		_ = fs.Parse(args[1:])
+		var before time.Time
+		if *days > 0 {
+			before = time.Now().AddDate(0, 0, -*days)
+		}
+		removed, err := utils.PruneHistory(before, *keep)
+		if err != nil {
+			fmt.Println(utils.T("[错误] 清理历史记录失败："), err)
+			return 1
+		}
+		fmt.Printf(utils.T("[信息] 已删除 %d 条历史记录。\n"), removed)
+	default:
+		fmt.Printf(utils.T("[错误] 未知的 history 命令：%s\n"), args[0])
+		return 1
+	}
+	return 0
+}
+
+func compareCommand(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	markdown := fs.Bool("md", false, utils.T("输出 Markdown"))
+	output := fs.String("o", "", utils.T("输出到文件"))
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Println(utils.T("用法：DockerST compare [-md] [-o 文件] <旧结果> <新结果>"))
+		fmt.Println(utils.T("  结果可以是结果文件（CSV/JSON/NDJSON），或 history:<ID>、history:latest、history:prev 指定的历史记录"))
+		return 1
+	}
+	old, err := utils.LoadComparedRun(fs.Arg(0))
+	if err != nil {
+		fmt.Println(utils.T("[错误]"), err)
+		return 1
+	}
+	current, err := utils.LoadComparedRun(fs.Arg(1))
+	if err != nil {
+		fmt.Println(utils.T("[错误]"), err)
+		return 1
+	}
+	if err = utils.CompareResults(old, current).WriteDiff(*output, *markdown); err != nil {
+		fmt.Println(utils.T("[错误]"), err)
+		return 1
+	}
+	return 0
+}
+
+func restoreCommand(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	restart := fs.Bool("restart", false, utils.T("还原后重启容器运行时服务"))
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println(utils.T("用法："))
+		fmt.Println(utils.T("  DockerST restore list                  列出全部备份"))
+		fmt.Println(utils.T("  DockerST restore [-restart] <ID|latest>  还原到指定备份之前的内容"))
+		fmt.Println(utils.T("  DockerST restore [-restart] purge      移除 DockerST 做过的全部修改"))
+		return 1
+	}
+	if fs.Arg(0) != "list" {
+		if err := utils.AcquireLock(); err != nil {
+			fmt.Println(utils.T("[错误]"), err)
+			return 1
+		}
+	}
+	var runtimes []string // 修改了配置的容器运行时
+	switch id := fs.Arg(0); id {
+	case "list":
+		records, err := utils.LoadBackups()
+		if err != nil {
+			fmt.Println(utils.T("[错误]"), err)
+			return 1
+		}
+		if len(records) == 0 {
+			fmt.Println(utils.T("[信息] 没有备份记录。"))
+			return 0
+		}
+		fmt.Printf("%-18s%-22s%-8s%-28s%s\n", "ID", utils.T("时间"), utils.T("类型"), utils.T("文件"), utils.T("修改内容"))
+		for _, r := range records {
+			fmt.Printf("%-18s%-22s%-8s%-28s%s\n", r.ID, r.Time.Format("2006-01-02 15:04:05"), r.Kind, r.Path, r.Change)
+		}
+		return 0
+	case "purge":
+		changed, err := utils.PurgeChanges()
+		for _, r := range changed {
+			fmt.Printf(utils.T("[信息] 已移除 %s 中 DockerST 的修改。\n"), r.Path)
+			runtimes = appendRuntime(runtimes, r.Kind)
+		}
+		if err != nil {
+			fmt.Println(utils.T("[错误]"), err)
+			return 1
+		}
+		if len(changed) == 0 {
+			fmt.Println(utils.T("[信息] 没有需要移除的修改。"))
+		}
+	default:
+		restored, err := utils.RestoreBackup(id)
+		if err != nil {
+			fmt.Println(utils.T("[错误]"), err)
+			return 1
+		}
+		for _, r := range restored {
+			fmt.Printf(utils.T("[信息] 已还原 %s（备份 %s）。\n"), r.Path, r.ID)
+			runtimes = appendRuntime(runtimes, r.Kind)
+		}
+	}
+	for _, name := range runtimes {
+		if !*restart {
+			fmt.Printf(utils.T("[提示] %s 配置已修改，需要重启 %s 服务才能生效（可使用 -restart 参数）。\n"), name, name)
+			continue
+		}
+		if err := utils.RestartRuntime(name); err != nil {
+			fmt.Println(utils.T("[错误]"), err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// 记录修改了配置且需要重启的容器运行时（hosts 及 containerd 的 hosts.toml 不需要重启）
+func appendRuntime(runtimes []string, kind string) []string {
+	if kind == utils.BackupHosts || slices.Contains(runtimes, kind) || !utils.NeedsRestart(kind) {
+		return runtimes
+	}
+	return append(runtimes, kind)
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"DockerST/task"
 	"DockerST/utils"
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -18,79 +22,249 @@ func init() {
 	var printVersion bool
 	var minDelay, maxDelay, downloadTime int
 	var maxLossRate float64
-	flag.IntVar(&task.Routines, "n", 200, "延迟测速线程")
-	flag.IntVar(&task.PingTimes, "t", 4, "延迟测速次数")
-	flag.IntVar(&task.TestCount, "dn", 10, "下载测速数量")
-	flag.IntVar(&downloadTime, "dt", 10, "下载测速时间")
-	flag.IntVar(&task.TCPPort, "tp", 443, "指定测速端口")
-	flag.StringVar(&task.URL, "url", "https://cf.xiu2.xyz/url", "指定测速地址")
-
-	flag.BoolVar(&task.Httping, "httping", false, "切换测速模式")
-	flag.IntVar(&task.HttpingStatusCode, "httping-code", 0, "有效状态代码")
-	flag.StringVar(&task.HttpingCFColo, "cfcolo", "", "匹配指定地区")
-
-	flag.IntVar(&maxDelay, "tl", 9999, "平均延迟上限")
-	flag.IntVar(&minDelay, "tll", 0, "平均延迟下限")
-	flag.Float64Var(&maxLossRate, "tlr", 1, "丢包几率上限")
-	flag.Float64Var(&task.MinSpeed, "sl", 0, "下载速度下限")
-
-	flag.IntVar(&utils.PrintNum, "p", 10, "显示结果数量")
-	flag.StringVar(&task.IPFile, "f", "ip.txt", "IP段数据文件")
-	flag.StringVar(&task.IPText, "ip", "", "指定IP段数据")
-	flag.StringVar(&utils.Output, "o", "result.csv", "输出结果文件")
-
-	flag.BoolVar(&task.IsOff, "off", false, "关闭在线读取列表")
-	flag.BoolVar(&task.Disable, "dd", false, "禁用下载测速")
-	flag.BoolVar(&task.TestAll, "allip", false, "测速全部 IP")
-
-	flag.BoolVar(&printVersion, "v", false, "打印程序版本")
-	flag.StringVar(&utils.DefaultDockerUrl, "docker-url", utils.DefaultDockerUrl, "Docker 地址")
-	flag.BoolVar(&task.IsOff, "om", false, "关闭在线读取列表")
+	var maxMB, maxTotalMB float64
+	var stableInterval, verifyTimeout int
+	var blacklistHours float64
+	var weights, lang, hosts string
+	utils.DetectLang(os.Args[1:]) // 参数说明也需要本地化，因此在定义参数前确定语言
+	flag.IntVar(&task.Routines, "n", 200, utils.T("延迟测速线程"))
+	flag.IntVar(&task.PingTimes, "t", 4, utils.T("延迟测速次数"))
+	flag.IntVar(&task.TestCount, "dn", 10, utils.T("下载测速数量"))
+	flag.IntVar(&downloadTime, "dt", 10, utils.T("下载测速时间"))
+	flag.IntVar(&task.TCPPort, "tp", 443, utils.T("指定测速端口"))
+	flag.StringVar(&task.URL, "url", "https://cf.xiu2.xyz/url", utils.T("指定测速地址"))
+	flag.StringVar(&task.Protocol, "proto", "h1", utils.T("测速协议 (h1/h2/h3)"))
+
+	flag.BoolVar(&task.Httping, "httping", false, utils.T("切换测速模式"))
+	flag.IntVar(&task.HttpingStatusCode, "httping-code", 0, utils.T("有效状态代码"))
+	flag.StringVar(&task.HttpingCFColo, "cfcolo", "", utils.T("匹配指定地区"))
+
+	flag.IntVar(&maxDelay, "tl", 9999, utils.T("平均延迟上限"))
+	flag.IntVar(&minDelay, "tll", 0, utils.T("平均延迟下限"))
+	flag.Float64Var(&maxLossRate, "tlr", 1, utils.T("丢包几率上限"))
+	flag.Float64Var(&task.MinSpeed, "sl", 0, utils.T("下载速度下限"))
+	flag.Float64Var(&maxMB, "dm", 0, utils.T("单个 IP 下载测速流量上限 (MB)"))
+	flag.Float64Var(&maxTotalMB, "dtm", 0, utils.T("下载测速总流量上限 (MB)"))
+
+	flag.IntVar(&task.StableTop, "st", 0, utils.T("稳定性测试数量"))
+	flag.IntVar(&task.StableRounds, "sr", 3, utils.T("稳定性测试轮数"))
+	flag.IntVar(&stableInterval, "si", 60, utils.T("稳定性测试间隔"))
+	flag.Float64Var(&task.StablePercentile, "sp", 0, utils.T("稳定性排名百分位"))
+
+	flag.StringVar(&utils.SortKey, "sort", "speed", utils.T("排序方式 (speed/delay/loss/score)"))
+	flag.StringVar(&weights, "w", utils.Weights.String(), utils.T("综合得分权重"))
+
+	flag.IntVar(&utils.PrintNum, "p", 10, utils.T("显示结果数量"))
+	flag.StringVar(&task.IPFile, "f", "ip.txt", utils.T("IP段数据文件"))
+	flag.StringVar(&task.IPText, "ip", "", utils.T("指定IP段数据"))
+	flag.StringVar(&task.RetestFile, "from", "", utils.T("重新测试结果文件中的 IP"))
+	flag.IntVar(&task.RetestTop, "from-top", 0, utils.T("重新测试结果文件前 N 个 IP"))
+	flag.StringVar(&utils.Output, "o", "result.csv", utils.T("输出结果文件"))
+	flag.StringVar(&utils.OutputFormat, "of", "", utils.T("输出结果格式 (csv/json/ndjson/md)"))
+	flag.StringVar(&utils.HTMLOutput, "html", "", utils.T("输出 HTML 报告文件"))
+	flag.StringVar(&utils.TraceOutput, "trace", "", utils.T("输出各阶段耗时诊断文件"))
+	flag.StringVar(&utils.MetricsFile, "metrics-file", "", utils.T("输出 Prometheus 指标文件"))
+	flag.StringVar(&utils.MetricsAddr, "metrics-addr", "", utils.T("Prometheus 指标监听地址"))
+	flag.StringVar(&utils.HistoryFile, "hf", "", utils.T("历史记录文件"))
+	flag.BoolVar(&utils.NoHistory, "nh", false, utils.T("不记录历史"))
+	flag.IntVar(&task.HistoryBest, "hk", 0, utils.T("重新测试历史最优 IP 数量"))
+	flag.IntVar(&task.BlacklistRuns, "hb", 0, utils.T("连续失败多少次后拉黑"))
+	flag.Float64Var(&blacklistHours, "hbe", 24, utils.T("黑名单有效期（小时）"))
+
+	flag.BoolVar(&task.IsOff, "off", false, utils.T("关闭在线读取列表"))
+	flag.BoolVar(&task.Disable, "dd", false, utils.T("禁用下载测速"))
+	flag.BoolVar(&task.TestAll, "allip", false, utils.T("测速全部 IP"))
+
+	flag.BoolVar(&utils.Quiet, "q", false, utils.T("安静模式（输出 JSON 摘要）"))
+	flag.BoolVar(&printVersion, "v", false, utils.T("打印程序版本"))
+	flag.StringVar(&utils.DefaultDockerUrl, "docker-url", utils.DefaultDockerUrl, utils.T("Docker 地址"))
+	flag.StringVar(&hosts, "hosts", "", utils.T("其他目标域名，逗号分隔（如 registry-1.docker.io,auth.docker.io）"))
+	flag.IntVar(&task.HostCandidates, "hosts-top", 10, utils.T("每个目标域名除 DNS 解析结果外额外测试的测速结果 IP 数量"))
+	flag.StringVar(&utils.DockerConfigPath, "docker-config", "", utils.T("Docker 配置文件路径（默认自动检测）"))
+	flag.StringVar(&utils.RuntimeName, "runtime", utils.RuntimeName, utils.T("容器运行时 (auto/docker/containerd/podman)"))
+	flag.StringVar(&utils.RestartPolicy, "restart", utils.RestartPolicy, utils.T("设置加速器后重启服务 (never/always/ask)"))
+	flag.BoolVar(&utils.NoVerify, "no-verify", false, utils.T("写入后不验证修改是否生效"))
+	flag.IntVar(&verifyTimeout, "verify-timeout", 30, utils.T("验证超时时间（秒），超时未通过时自动还原"))
+	flag.StringVar(&utils.VerifyImage, "verify-image", utils.VerifyImage, utils.T("验证时通过加速器获取清单的镜像"))
+	flag.BoolVar(&utils.DryRun, "dry-run", false, utils.T("演练模式，只显示将要进行的修改，不写入任何文件（结果文件、历史记录、指标文件等）"))
+	flag.StringVar(&utils.MirrorMode, "mirror-mode", utils.MirrorMode, utils.T("加速器写入方式 (prepend/append/replace)"))
+	flag.BoolVar(&task.IsOff, "om", false, utils.T("关闭在线读取列表"))
+	flag.StringVar(&lang, "lang", "", utils.T("输出语言 (zh-CN/en)"))
+	flag.Parse()
+	if err := utils.CheckLang(lang); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
 
 	if task.MinSpeed > 0 && time.Duration(maxDelay)*time.Millisecond == utils.InputMaxDelay {
-		fmt.Println("[小提示] 在使用 [-sl] 参数时，建议搭配 [-tl] 参数，以避免因凑不够 [-dn] 数量而一直测速...")
+		utils.Println(utils.T("[小提示] 在使用 [-sl] 参数时，建议搭配 [-tl] 参数，以避免因凑不够 [-dn] 数量而一直测速..."))
 	}
 	utils.InputMaxDelay = time.Duration(maxDelay) * time.Millisecond
 	utils.InputMinDelay = time.Duration(minDelay) * time.Millisecond
 	utils.InputMaxLossRate = float32(maxLossRate)
 	task.Timeout = time.Duration(downloadTime) * time.Second
+	task.MaxBytes = int64(maxMB * 1024 * 1024)
+	task.MaxTotalBytes = int64(maxTotalMB * 1024 * 1024)
+	task.StableInterval = time.Duration(stableInterval) * time.Second
+	task.BlacklistExpiry = time.Duration(blacklistHours * float64(time.Hour))
+	utils.VerifyTimeout = time.Duration(verifyTimeout) * time.Second
 	task.HttpingCFColomap = task.MapColoMap()
+	if hosts != "" {
+		utils.TargetHosts = strings.Split(hosts, ",")
+	}
+	if utils.DryRun { // 演练模式不写入任何文件，指标只通过 -metrics-addr 提供
+		utils.Output, utils.HTMLOutput, utils.TraceOutput, utils.MetricsFile = "", "", "", ""
+		utils.NoHistory = true
+	}
+	utils.Params = runParams()
+	if err := task.CheckProtocol(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	if err := utils.CheckOutputFormat(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	if err := utils.CheckRuntime(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	if err := utils.CheckMirrorMode(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	if err := utils.CheckRestartPolicy(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	if err := utils.CheckSortKey(); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
+	var err error
+	if utils.Weights, err = utils.ParseWeights(weights); err != nil {
+		utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+	}
 
 	if printVersion {
 		println(version)
-		fmt.Println("检查版本更新中...")
+		fmt.Println(utils.T("检查版本更新中..."))
 		checkUpdate()
 		if versionNew != "" {
-			fmt.Printf("*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***", versionNew)
+			fmt.Printf(utils.T("*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***"), versionNew)
 		} else {
-			fmt.Println("当前为最新版本 [" + version + "]！")
+			fmt.Printf(utils.T("当前为最新版本 [%s]！\n"), version)
 		}
 		os.Exit(0)
 	}
 }
 
 func main() {
+	if flag.NArg() > 0 { // 子命令，如 history
+		os.Exit(runCommand(flag.Args()))
+	}
+	if !utils.DryRun { // 同一时间只允许一个 DockerST 修改系统配置，演练模式不修改，无需加锁
+		if err := utils.AcquireLock(); err != nil {
+			utils.Finish(utils.ExitError, err, nil, utils.RunSummary{})
+		}
+	}
 	task.InitRandSeed() // 置随机数种子
-	fmt.Printf("# sxhoio/DockerST %s \n\n", version)
+	utils.Printf("# sxhoio/DockerST %s \n\n", version)
+	startTime := time.Now()
+	if utils.MetricsAddr != "" {
+		if err := utils.ServeMetrics(utils.MetricsAddr); err != nil {
+			utils.Finish(utils.ExitError, fmt.Errorf(utils.T("启动指标服务失败：%w"), err), nil, utils.RunSummary{})
+		}
+	}
 	// 开始延迟测速 + 过滤延迟/丢包
-	pingData := task.NewPing().Run().FilterDelay().FilterLossRate()
+	allPingData := task.NewPing().Run()
+	pingData := allPingData.FilterDelay().FilterLossRate()
 	// 开始下载测速
 	speedData := task.TestDownloadSpeed(pingData)
+	// 稳定性测试（重新排名前 N 个 IP）
+	speedData = task.TestStability(speedData)
+	speedData.Rank() // 计算综合得分并排序
+	// 输出各阶段耗时诊断结果，失败时仍继续应用最优 IP
+	if err := utils.ExportTrace(); err != nil {
+		utils.Printf(utils.T("\n[警告] 输出各阶段耗时诊断结果失败：%v\n"), err)
+	}
+
+	utils.Params = runParams()
+	utils.DownloadBytes = task.TotalBytes
+	if err := utils.Export(speedData); err != nil { // 输出结果文件，失败时仍继续应用最优 IP
+		utils.Printf(utils.T("\n[警告] 输出结果文件失败：%v\n"), err)
+		utils.Output = "" // 结果文件未写入，不再提示已写入
+	}
+	speedData.Print() // 打印结果
+	if task.RetestFile != "" {
+		utils.PrintRetest(task.RetestBaseline, speedData)
+	}
+	saveHistory(allPingData, speedData)
+
+	utils.HostIPs = task.TestHosts(speedData) // 为其他目标域名选出各自的最优 IP
 
-	speedData.DockerSet() // 替换节点
+	applyErr := speedData.DockerSet() // 替换节点
+	utils.ExportHTML(speedData)       // 输出 HTML 报告
+	summary := utils.RunSummary{
+		Start:     startTime,
+		Duration:  time.Since(startTime),
+		Tested:    len(allPingData) + len(task.FailedIPs),
+		Available: len(allPingData),
+		Filtered:  len(pingData),
+		Download:  len(speedData),
+	}
+	utils.UpdateMetrics(speedData, summary)
+	utils.ReleaseLock() // 修改及验证已完成，之后只提供指标服务，不再阻止其他 DockerST 运行
 
 	if versionNew != "" {
-		fmt.Printf("\n*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***\n", versionNew)
+		utils.Printf(utils.T("\n*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***\n"), versionNew)
+	}
+	if utils.MetricsAddr != "" { // 持续提供 /metrics，直到收到退出信号
+		utils.Printf(utils.T("\n[信息] 指标服务运行中（%s/metrics），按 Ctrl+C 退出。\n"), utils.MetricsAddr)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		<-ctx.Done()
+		stop()
+	} else {
+		endPrint()
+	}
+	utils.Finish(utils.ExitCode(applyErr), applyErr, speedData, summary)
+}
+
+// 本次运行的测速参数
+func runParams() utils.RunParams {
+	mode := "tcp"
+	if task.Httping {
+		mode = "http"
+	}
+	return utils.RunParams{
+		Version:          version,
+		Mode:             mode,
+		Port:             task.TCPPort,
+		URL:              task.URL,
+		Protocol:         task.Protocol,
+		Routines:         task.Routines,
+		PingTimes:        task.PingTimes,
+		TestCount:        task.TestCount,
+		DownloadTimeSec:  task.Timeout.Seconds(),
+		MaxDelayMs:       utils.InputMaxDelay.Milliseconds(),
+		MinDelayMs:       utils.InputMinDelay.Milliseconds(),
+		MaxLossRate:      utils.InputMaxLossRate,
+		MinSpeedMBps:     task.MinSpeed,
+		MaxBytes:         task.MaxBytes,
+		MaxTotalBytes:    task.MaxTotalBytes,
+		IPFile:           task.IPFile,
+		IPText:           task.IPText,
+		RetestFile:       task.RetestFile,
+		TestAll:          task.TestAll,
+		StableTop:        task.StableTop,
+		StableRounds:     task.StableRounds,
+		StablePercentile: task.StablePercentile,
+		DockerURL:        utils.DefaultDockerUrl,
+		Hosts:            utils.ExtraHosts(),
+		SortKey:          utils.SortKey,
+		Weights:          utils.Weights.String(),
 	}
-	endPrint()
 }
 
 func endPrint() {
-	if utils.NoPrintResult() {
+	if utils.NoPrintResult() || utils.Quiet {
 		return
 	}
 	if runtime.GOOS == "windows" { // 如果是 Windows 系统，则需要按下 回车键 或 Ctrl+C 退出（避免通过双击运行时，测速完毕后直接关闭）
-		fmt.Printf("按下 回车键 或 Ctrl+C 退出。")
+		fmt.Printf(utils.T("按下 回车键 或 Ctrl+C 退出。"))
 		_, _ = fmt.Scanln()
 	}
 }
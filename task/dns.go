@@ -0,0 +1,116 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	// 系统 DNS 服务器配置文件
+	resolvConf = "/etc/resolv.conf"
+	// 读取不到系统 DNS 服务器（如 Windows）时使用的公共 DNS
+	fallbackNameservers = []string{"223.5.5.5", "119.29.29.29"}
+)
+
+// 直接向 DNS 服务器查询域名的 A 及 AAAA 记录，不读取 hosts 文件
+// hosts 中可能有 DockerST 写入的记录，系统解析会直接返回这些 IP，无法得到域名当前的解析结果
+func lookupDNS(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	name, err := dnsmessage.NewName(host)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, server := range nameservers() {
+		a, errA := queryDNS(ctx, server, name, dnsmessage.TypeA)
+		aaaa, errAAAA := queryDNS(ctx, server, name, dnsmessage.TypeAAAA)
+		if errA != nil && errAAAA != nil { // 该 DNS 服务器不可用，尝试下一个
+			lastErr = errA
+			continue
+		}
+		addrs := append(a, aaaa...)
+		if len(addrs) == 0 {
+			return nil, &net.DNSError{Err: "no such host", Name: strings.TrimSuffix(host, "."), Server: server, IsNotFound: true}
+		}
+		return addrs, nil
+	}
+	return nil, lastErr
+}
+
+// 系统配置的 DNS 服务器地址（含端口）
+func nameservers() []string {
+	var servers []string
+	if content, err := os.ReadFile(resolvConf); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		for _, server := range fallbackNameservers {
+			servers = append(servers, net.JoinHostPort(server, "53"))
+		}
+	}
+	return servers
+}
+
+// 通过 UDP 向指定 DNS 服务器查询一种记录，域名不存在时返回空结果
+func queryDNS(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]net.IPAddr, error) {
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1500)
+	var resp dnsmessage.Message
+	for { // 忽略不属于本次查询的响应
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if err = resp.Unpack(buf[:n]); err == nil && resp.ID == id && resp.Response {
+			break
+		}
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: %s", server, resp.RCode)
+	}
+	var addrs []net.IPAddr
+	for _, answer := range resp.Answers { // CNAME 由 DNS 服务器递归解析，只取其中的 IP
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.A[:])})
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.AAAA[:])})
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,96 @@
+package task
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 在本地 UDP 端口模拟 DNS 服务器：registry.example.com 为指向 1.2.3.4 的 CNAME，其他域名不存在
+func startDNSServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听 UDP 端口: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			resp := dnsmessage.Message{Header: dnsmessage.Header{ID: query.ID, Response: true}, Questions: query.Questions}
+			if q.Name.String() != "registry.example.com." {
+				resp.RCode = dnsmessage.RCodeNameError
+			} else if q.Type == dnsmessage.TypeA {
+				target := dnsmessage.MustNewName("edge.example.net.")
+				resp.Answers = []dnsmessage.Resource{
+					{Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET}, Body: &dnsmessage.CNAMEResource{CNAME: target}},
+					{Header: dnsmessage.ResourceHeader{Name: target, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}, Body: &dnsmessage.AResource{A: [4]byte{1, 2, 3, 4}}},
+				}
+			}
+			// 先发送一个不属于本次查询的响应
+			stale := dnsmessage.Message{Header: dnsmessage.Header{ID: query.ID + 1, Response: true}, Questions: query.Questions}
+			for _, m := range []dnsmessage.Message{stale, resp} {
+				packed, err := m.Pack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, _ = conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestQueryDNS(t *testing.T) {
+	server := startDNSServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	name := dnsmessage.MustNewName("registry.example.com.")
+	addrs, err := queryDNS(ctx, server, name, dnsmessage.TypeA)
+	if err != nil || len(addrs) != 1 || !addrs[0].IP.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Errorf("queryDNS(A) = %v, %v", addrs, err)
+	}
+	if addrs, err := queryDNS(ctx, server, name, dnsmessage.TypeAAAA); err != nil || len(addrs) != 0 {
+		t.Errorf("queryDNS(AAAA) = %v, %v", addrs, err)
+	}
+	if addrs, err := queryDNS(ctx, server, dnsmessage.MustNewName("missing.example.com."), dnsmessage.TypeA); err != nil || len(addrs) != 0 {
+		t.Errorf("queryDNS(missing) = %v, %v", addrs, err)
+	}
+}
+
+func TestNameservers(t *testing.T) {
+	oldConf := resolvConf
+	t.Cleanup(func() { resolvConf = oldConf })
+	resolvConf = filepath.Join(t.TempDir(), "resolv.conf")
+	content := "# generated\nnameserver 10.0.0.1\nsearch example.com\nnameserver fe80::1%eth0\noptions ndots:1\n"
+	if err := os.WriteFile(resolvConf, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nameservers(), []string{"10.0.0.1:53", "[fe80::1%eth0]:53"}; !slices.Equal(got, want) {
+		t.Errorf("nameservers() = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(resolvConf, []byte("search example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := nameservers(); len(got) != len(fallbackNameservers) {
+		t.Errorf("nameservers() = %v, want fallback", got)
+	}
+}
@@ -3,13 +3,18 @@ package task
 import (
 	"DockerST/utils"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/VividCortex/ewma"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +25,7 @@ const (
 	defaultDisableDownload         = false
 	defaultTestNum                 = 10
 	defaultMinSpeed        float64 = 0.0
+	defaultProtocol                = "h1"
 )
 
 var (
@@ -29,6 +35,17 @@ var (
 
 	TestCount = defaultTestNum
 	MinSpeed  = defaultMinSpeed
+	// Protocol HTTP 延迟测速及下载测速使用的协议：h1、h2、h3
+	Protocol = defaultProtocol
+	// 连接测速地址时使用的 TLS 配置，nil 为默认配置（测试时替换为信任本地证书的配置）
+	tlsClientConfig *tls.Config
+
+	// MaxBytes 单个 IP 下载测速的流量上限（字节），0 为不限制
+	MaxBytes int64
+	// MaxTotalBytes 本次运行下载测速的总流量上限（字节），0 为不限制
+	MaxTotalBytes int64
+	// TotalBytes 本次运行下载测速已消耗的流量（字节）
+	TotalBytes int64
 )
 
 func checkDownloadDefault() {
@@ -44,6 +61,45 @@ func checkDownloadDefault() {
 	if MinSpeed <= 0.0 {
 		MinSpeed = defaultMinSpeed
 	}
+	if MaxBytes < 0 {
+		MaxBytes = 0
+	}
+	if MaxTotalBytes < 0 {
+		MaxTotalBytes = 0
+	}
+	if Protocol == "" {
+		Protocol = defaultProtocol
+	}
+}
+
+// CheckProtocol 检查指定的协议是否可用
+func CheckProtocol() error {
+	switch Protocol {
+	case "", "h1", "h2":
+		return nil
+	case "h3":
+		if !strings.HasPrefix(URL, "https://") {
+			return fmt.Errorf(utils.T("HTTP/3 (QUIC) 只支持 https:// 测速地址：%s"), URL)
+		}
+		return nil
+	default:
+		return fmt.Errorf(utils.T("未知的协议：%s（可选 h1、h2、h3）"), Protocol)
+	}
+}
+
+// 返回本次下载测速允许使用的流量（字节），0 为不限制，-1 为总流量已用完
+func nextByteLimit() int64 {
+	if MaxTotalBytes <= 0 {
+		return MaxBytes
+	}
+	remain := MaxTotalBytes - TotalBytes
+	if remain <= 0 {
+		return -1
+	}
+	if MaxBytes > 0 && MaxBytes < remain {
+		return MaxBytes
+	}
+	return remain
 }
 
 func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSet) {
@@ -52,7 +108,7 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 		return utils.DownloadSpeedSet(ipSet)
 	}
 	if len(ipSet) <= 0 { // IP数组长度(IP数量) 大于 0 时才会继续下载测速
-		fmt.Println("\n[信息] 延迟测速结果 IP 数量为 0，跳过下载测速。")
+		utils.Println(utils.T("\n[信息] 延迟测速结果 IP 数量为 0，跳过下载测速。"))
 		return
 	}
 	testNum := TestCount
@@ -63,27 +119,58 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 		TestCount = testNum
 	}
 
-	fmt.Printf("开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d）\n", MinSpeed, TestCount, testNum)
+	utils.Printf(utils.T("开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d, 协议：%s）\n"), MinSpeed, TestCount, testNum, Protocol)
 	// 控制 下载测速进度条 与 延迟测速进度条 长度一致（强迫症）
 	bar_a := len(strconv.Itoa(len(ipSet)))
 	bar_b := "     "
 	for i := 0; i < bar_a; i++ {
 		bar_b += " "
 	}
-	bar := utils.NewBar(TestCount, bar_b, "")
+	// 终端输出时显示实时进度及已完成结果，否则使用进度条
+	live := utils.NewLiveView(testNum)
+	var bar *utils.Bar
+	if live.Enabled() {
+		live.Header()
+	} else {
+		bar = utils.NewBar(TestCount, bar_b, "")
+	}
 	for i := 0; i < testNum; i++ {
-		speed := downloadHandler(ipSet[i].IP)
-		ipSet[i].DownloadSpeed = speed
+		limit := nextByteLimit()
+		if limit < 0 { // 总流量已用完，停止下载测速
+			utils.Printf(utils.T("\n[信息] 已达到下载测速总流量上限（%.2f MB），停止下载测速。\n"), float64(MaxTotalBytes)/1024/1024)
+			break
+		}
+		ip := ipSet[i].IP.String()
+		result := downloadHandler(ipSet[i].IP, limit, func(current, average float64, read int64, remain time.Duration) {
+			live.Update(ip, current, average, read, remain)
+		})
+		TotalBytes += result.bytes
+		ipSet[i].DownloadSpeed = result.speed
+		ipSet[i].DownloadBytes = result.bytes
+		ipSet[i].Trace = result.trace
+		if result.proto != "" {
+			ipSet[i].Protocol = result.proto
+		}
+		if result.colo != "" {
+			ipSet[i].Colo = result.colo
+		}
 		// 在每个 IP 下载测速后，以 [下载速度下限] 条件过滤结果
-		if speed >= MinSpeed*1024*1024 {
-			bar.Grow(1, "")
+		passed := result.speed >= MinSpeed*1024*1024
+		live.Done(ipSet[i], passed)
+		if passed {
+			if bar != nil {
+				bar.Grow(1, "")
+			}
 			speedSet = append(speedSet, ipSet[i]) // 高于下载速度下限时，添加到新数组中
 			if len(speedSet) == TestCount {       // 凑够满足条件的 IP 时（下载测速数量 -dn），就跳出循环
 				break
 			}
 		}
 	}
-	bar.Done()
+	if bar != nil {
+		bar.Done()
+	}
+	utils.Printf(utils.T("[信息] 下载测速共消耗流量：%.2f MB\n"), float64(TotalBytes)/1024/1024)
 	if len(speedSet) == 0 { // 没有符合速度限制的数据，返回所有测试数据
 		speedSet = utils.DownloadSpeedSet(ipSet)
 	}
@@ -92,22 +179,79 @@ func TestDownloadSpeed(ipSet utils.PingDelaySet) (speedSet utils.DownloadSpeedSe
 	return
 }
 
-func getDialContext(ip *net.IPAddr) func(ctx context.Context, network, address string) (net.Conn, error) {
-	var fakeSourceAddr string
-	if isIPv4(ip.String()) {
-		fakeSourceAddr = fmt.Sprintf("%s:%d", ip.String(), TCPPort)
-	} else {
-		fakeSourceAddr = fmt.Sprintf("[%s]:%d", ip.String(), TCPPort)
-	}
+// 连接到指定 IP 及端口，忽略请求地址中的域名及端口
+func getDialContext(ip *net.IPAddr, port int) func(ctx context.Context, network, address string) (net.Conn, error) {
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
 	return func(ctx context.Context, network, address string) (net.Conn, error) {
-		return (&net.Dialer{}).DialContext(ctx, network, fakeSourceAddr)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
 	}
 }
 
-// return download Speed
-func downloadHandler(ip *net.IPAddr) float64 {
+// 根据指定协议创建连接到指定 IP 及端口的 RoundTripper
+// h2 需要 TLS（ALPN 协商），对 http:// 地址会回落到 HTTP/1.1，实际协商结果以 Response.Proto 为准
+// h3 通过 QUIC（UDP）连接，只支持 https:// 地址
+// 每个 Transport 使用各自的 TLS 配置副本，Transport 会在握手时修改配置（如 NextProtos）
+func newTransport(ip *net.IPAddr, port int) http.RoundTripper {
+	if Protocol == "h3" {
+		addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+		return &http3.Transport{
+			TLSClientConfig: tlsClientConfig.Clone(),
+			Dial: func(ctx context.Context, _ string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+				return dialQUIC(ctx, addr, tlsCfg, cfg)
+			},
+		}
+	}
+	transport := &http.Transport{DialContext: getDialContext(ip, port), TLSClientConfig: tlsClientConfig.Clone()}
+	if Protocol == "h2" {
+		transport.ForceAttemptHTTP2 = true
+	} else { // 禁用 HTTP/2 协商，强制使用 HTTP/1.1
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
+
+// 建立 QUIC 连接并等待握手完成，QUIC 的连接与 TLS 握手是同一步，在 httptrace 中记为 TLS 握手阶段
+func dialQUIC(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+	conn, err := quic.DialAddrEarly(ctx, addr, tlsCfg, cfg)
+	if err == nil {
+		select {
+		case <-conn.HandshakeComplete():
+		case <-ctx.Done():
+			_ = conn.CloseWithError(0, "")
+			conn, err = nil, context.Cause(ctx)
+		}
+	}
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		var state tls.ConnectionState
+		if conn != nil {
+			state = conn.ConnectionState().TLS
+		}
+		trace.TLSHandshakeDone(state, err)
+	}
+	return conn, err
+}
+
+// 单个 IP 的下载测速结果
+type downloadResult struct {
+	speed float64 // 下载速度（B/s）
+	bytes int64   // 消耗的流量
+	proto string  // 实际协商的协议
+	colo  string  // 响应头中的地区三字码
+	trace *utils.TraceData
+}
+
+// limit 大于 0 时，优先使用 Range 请求只下载前 limit 字节，服务器不支持时读够 limit 字节后提前断开连接
+// progress 在每个时间片回调一次实时进度：当前速度、平均速度（B/s）、已下载字节及剩余时间，可为 nil
+func downloadHandler(ip *net.IPAddr, limit int64, progress func(current, average float64, read int64, remain time.Duration)) (result downloadResult) {
+	defer func() {
+		utils.AddTrace(ip.String(), "download", result.trace)
+	}()
 	client := &http.Client{
-		Transport: &http.Transport{DialContext: getDialContext(ip)},
+		Transport: newTransport(ip, TCPPort),
 		Timeout:   Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) > 10 { // 限制最多重定向 10 次
@@ -119,20 +263,30 @@ func downloadHandler(ip *net.IPAddr) float64 {
 			return nil
 		},
 	}
+	defer client.CloseIdleConnections() // h3 的 QUIC 连接不会随 Client 自动关闭
 	req, err := http.NewRequest("GET", URL, nil)
 	if err != nil {
-		return 0.0
+		result.trace = &utils.TraceData{Failure: failureReason(err)}
+		return
 	}
 
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
+	if limit > 0 {
+		req.Header.Set("Range", "bytes=0-"+strconv.FormatInt(limit-1, 10))
+	}
 
+	req, tracer := withTrace(req)
 	response, err := client.Do(req)
 	if err != nil {
-		return 0.0
+		result.trace = tracer.data(time.Now(), failureReason(err))
+		return
 	}
-	defer response.Body.Close()
-	if response.StatusCode != 200 {
-		return 0.0
+	defer response.Body.Close() // 未读完时关闭会直接断开连接，不再继续消耗流量
+	result.proto = response.Proto
+	result.colo = OutRegexp.FindString(cfRayOf(response.Header))
+	if response.StatusCode != 200 && response.StatusCode != 206 {
+		result.trace = tracer.data(time.Now(), statusReason(response.StatusCode))
+		return
 	}
 	timeStart := time.Now()           // 开始时间（当前）
 	timeEnd := timeStart.Add(Timeout) // 加上下载测速时间得到的结束时间
@@ -157,6 +311,11 @@ func downloadHandler(ip *net.IPAddr) float64 {
 			timeCounter++
 			nextTime = timeStart.Add(timeSlice * time.Duration(timeCounter))
 			e.Add(float64(contentRead - lastContentRead))
+			if progress != nil {
+				current := float64(contentRead-lastContentRead) / timeSlice.Seconds()
+				average := float64(contentRead) / currentTime.Sub(timeStart).Seconds()
+				progress(current, average, contentRead, timeEnd.Sub(currentTime))
+			}
 			lastContentRead = contentRead
 		}
 		// 如果超出下载测速时间，则退出循环（终止测速）
@@ -176,6 +335,15 @@ func downloadHandler(ip *net.IPAddr) float64 {
 			e.Add(float64(contentRead-lastContentRead) / (float64(currentTime.Sub(last_time_slice)) / float64(timeSlice)))
 		}
 		contentRead += int64(bufferRead)
+		// 达到流量上限（服务器不支持 Range 请求时），则退出循环（终止测速）
+		if limit > 0 && contentRead >= limit {
+			last_time_slice := timeStart.Add(timeSlice * time.Duration(timeCounter-1))
+			e.Add(float64(contentRead-lastContentRead) / (float64(time.Since(last_time_slice)) / float64(timeSlice)))
+			break
+		}
 	}
-	return e.Value() / (Timeout.Seconds() / 120)
+	result.speed = e.Value() / (Timeout.Seconds() / 120)
+	result.bytes = contentRead
+	result.trace = tracer.data(time.Now(), "")
+	return
 }
@@ -0,0 +1,222 @@
+package task
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+const testBodySize = 256 * 1024
+
+// 返回固定大小内容的下载测速地址
+func testHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Length", strconv.Itoa(testBodySize))
+	_, _ = w.Write(make([]byte, testBodySize))
+}
+
+// 启动同时支持 HTTP/1.1 及 HTTP/2 的本地 TLS 服务器，并让测速使用信任其证书的 TLS 配置
+func startTLSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(testHandler))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	oldConfig := tlsClientConfig
+	tlsClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	t.Cleanup(func() { tlsClientConfig = oldConfig })
+	return srv
+}
+
+// 使用 TLS 服务器的证书在本地 UDP 端口启动 HTTP/3 服务器，返回端口
+func startQUICServer(t *testing.T, srv *httptest.Server) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听 UDP 端口: %v", err)
+	}
+	h3 := &http3.Server{
+		Handler:   http.HandlerFunc(testHandler),
+		TLSConfig: http3.ConfigureTLSConfig(srv.TLS.Clone()),
+	}
+	go func() {
+		_ = h3.Serve(conn)
+	}()
+	t.Cleanup(func() {
+		_ = h3.Close()
+		_ = conn.Close()
+	})
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func setDownloadParams(t *testing.T, protocol string, port int) {
+	t.Helper()
+	oldProtocol, oldURL, oldPort, oldTimeout := Protocol, URL, TCPPort, Timeout
+	t.Cleanup(func() {
+		Protocol, URL, TCPPort, Timeout = oldProtocol, oldURL, oldPort, oldTimeout
+	})
+	// 测速地址的域名只用于 SNI 及 Host，实际连接到指定的 IP 及端口
+	Protocol, URL, TCPPort, Timeout = protocol, "https://example.com/file", port, 5*time.Second
+}
+
+func TestDownloadProtocols(t *testing.T) {
+	srv := startTLSServer(t)
+	tlsPort := srv.Listener.Addr().(*net.TCPAddr).Port
+	ip := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+
+	tests := []struct {
+		protocol string
+		port     func() int
+		proto    string
+	}{
+		{"h1", func() int { return tlsPort }, "HTTP/1.1"},
+		{"h2", func() int { return tlsPort }, "HTTP/2.0"},
+		{"h3", func() int { return startQUICServer(t, srv) }, "HTTP/3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			setDownloadParams(t, tt.protocol, tt.port())
+			if err := CheckProtocol(); err != nil {
+				t.Fatalf("CheckProtocol() = %v", err)
+			}
+			result := downloadHandler(ip, 0, nil)
+			if result.trace != nil && result.trace.Failure != "" {
+				t.Fatalf("下载失败: %s", result.trace.Failure)
+			}
+			if result.proto != tt.proto {
+				t.Errorf("proto = %q, want %q", result.proto, tt.proto)
+			}
+			if result.bytes != testBodySize {
+				t.Errorf("bytes = %d, want %d", result.bytes, testBodySize)
+			}
+		})
+	}
+}
+
+func TestDownloadByteLimit(t *testing.T) {
+	srv := startTLSServer(t)
+	setDownloadParams(t, "h1", srv.Listener.Addr().(*net.TCPAddr).Port)
+	result := downloadHandler(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 64*1024, nil)
+	// 测试服务器不支持 Range 请求，读够上限后提前断开
+	if result.bytes < 64*1024 || result.bytes >= testBodySize {
+		t.Errorf("bytes = %d, want about %d", result.bytes, 64*1024)
+	}
+}
+
+func TestDownloadRange(t *testing.T) {
+	ranges := make(chan string, 2) // 服务器收到的 Range 请求头
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges <- r.Header.Get("Range")
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(make([]byte, testBodySize)))
+	}))
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	oldConfig := tlsClientConfig
+	tlsClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	t.Cleanup(func() { tlsClientConfig = oldConfig })
+	setDownloadParams(t, "h1", srv.Listener.Addr().(*net.TCPAddr).Port)
+
+	result := downloadHandler(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 64*1024, nil)
+	if got := <-ranges; got != "bytes=0-65535" {
+		t.Errorf("Range = %q, want bytes=0-65535", got)
+	}
+	// 服务器只返回请求的部分（206），读完即结束
+	if result.trace == nil || result.trace.Failure != "" || result.bytes != 64*1024 {
+		t.Errorf("downloadHandler() = %+v, bytes %d, want %d", result.trace, result.bytes, 64*1024)
+	}
+
+	result = downloadHandler(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0, nil)
+	if got := <-ranges; got != "" || result.bytes != testBodySize {
+		t.Errorf("不限制流量时 bytes = %d, Range = %q", result.bytes, got)
+	}
+}
+
+func TestNextByteLimit(t *testing.T) {
+	oldMax, oldTotalMax, oldTotal := MaxBytes, MaxTotalBytes, TotalBytes
+	defer func() { MaxBytes, MaxTotalBytes, TotalBytes = oldMax, oldTotalMax, oldTotal }()
+
+	tests := []struct {
+		max, totalMax, total, want int64
+	}{
+		{0, 0, 1000, 0},       // 不限制
+		{100, 0, 1000, 100},   // 只限制单个 IP
+		{0, 1000, 300, 700},   // 只限制总流量，使用剩余流量
+		{100, 1000, 300, 100}, // 单个 IP 上限小于剩余流量
+		{500, 1000, 800, 200}, // 剩余流量小于单个 IP 上限
+		{100, 1000, 1000, -1}, // 总流量已用完
+		{100, 1000, 1200, -1}, // 最后一个 IP 超出总流量
+	}
+	for _, tt := range tests {
+		MaxBytes, MaxTotalBytes, TotalBytes = tt.max, tt.totalMax, tt.total
+		if got := nextByteLimit(); got != tt.want {
+			t.Errorf("nextByteLimit(max=%d, total max=%d, used=%d) = %d, want %d", tt.max, tt.totalMax, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestCheckProtocol(t *testing.T) {
+	oldProtocol, oldURL := Protocol, URL
+	defer func() { Protocol, URL = oldProtocol, oldURL }()
+
+	tests := []struct {
+		protocol, url string
+		ok            bool
+	}{
+		{"h1", "http://example.com/", true},
+		{"h2", "https://example.com/", true},
+		{"h3", "https://example.com/", true},
+		{"h3", "http://example.com/", false},
+		{"h4", "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		Protocol, URL = tt.protocol, tt.url
+		if err := CheckProtocol(); (err == nil) != tt.ok {
+			t.Errorf("CheckProtocol(%s, %s) = %v", tt.protocol, tt.url, err)
+		} else if err != nil && !strings.Contains(err.Error(), tt.protocol) && !strings.Contains(err.Error(), tt.url) {
+			t.Errorf("CheckProtocol(%s, %s) error %q 未说明原因", tt.protocol, tt.url, err)
+		}
+	}
+}
+
+func TestDownloadProgress(t *testing.T) {
+	// 分段缓慢返回内容，下载过程中会经过多个时间片
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(testBodySize))
+		for i := 0; i < 8; i++ {
+			_, _ = w.Write(make([]byte, testBodySize/8))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	oldConfig := tlsClientConfig
+	tlsClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	t.Cleanup(func() { tlsClientConfig = oldConfig })
+	setDownloadParams(t, "h1", srv.Listener.Addr().(*net.TCPAddr).Port)
+	Timeout = time.Second
+
+	var calls int
+	var lastRead int64
+	result := downloadHandler(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0, func(current, average float64, read int64, remain time.Duration) {
+		calls++
+		if read < lastRead || read > testBodySize || current < 0 || average < 0 || remain > time.Second {
+			t.Errorf("progress(%v, %v, %d, %v), 上次已下载 %d", current, average, read, remain, lastRead)
+		}
+		lastRead = read
+	})
+	if result.bytes != testBodySize {
+		t.Errorf("bytes = %d, want %d", result.bytes, testBodySize)
+	}
+	if calls < 2 {
+		t.Errorf("progress 回调 %d 次，want >= 2", calls)
+	}
+}
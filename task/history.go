@@ -0,0 +1,67 @@
+package task
+
+import (
+	"DockerST/utils"
+	"net"
+	"time"
+)
+
+const defaultBlacklistExpiry = 24 * time.Hour
+
+var (
+	// HistoryBest 每次额外重新测试历史得分最高的 K 个 IP，0 为关闭
+	HistoryBest int
+	// BlacklistRuns 最近 M 次参与测速均失败的 IP 暂时拉黑，0 为关闭
+	BlacklistRuns int
+	// BlacklistExpiry 拉黑的有效期（从最后一次失败开始计算）
+	BlacklistExpiry = defaultBlacklistExpiry
+)
+
+// 根据历史记录调整待测速 IP：剔除黑名单中的 IP，并加入历史最优的 IP
+// 重新测试结果文件（-from）时只测试文件中的 IP，不做调整
+func applyHistory(ips []*net.IPAddr) []*net.IPAddr {
+	if RetestFile != "" || HistoryBest <= 0 && BlacklistRuns <= 0 {
+		return ips
+	}
+	runs, err := utils.LoadHistory()
+	if err != nil {
+		utils.Println(utils.T("[错误] 读取历史记录失败："), err)
+		return ips
+	}
+	if len(runs) == 0 {
+		return ips
+	}
+	if BlacklistExpiry <= 0 {
+		BlacklistExpiry = defaultBlacklistExpiry
+	}
+	scores := utils.HistoryScoreMap(runs)
+	utils.HistoryScores = scores
+	blacklist := utils.HistoryBlacklist(runs, BlacklistRuns, BlacklistExpiry, time.Now())
+
+	result := make([]*net.IPAddr, 0, len(ips)+HistoryBest)
+	seen := make(map[string]bool, len(ips))
+	skipped := 0
+	for _, ip := range ips {
+		if _, ok := blacklist[ip.String()]; ok {
+			skipped++
+			continue
+		}
+		seen[ip.String()] = true
+		result = append(result, ip)
+	}
+	added := 0
+	if HistoryBest > 0 {
+		for _, v := range utils.HistoryBest(scores, HistoryBest, blacklist) {
+			if seen[v] {
+				continue
+			}
+			if ip := net.ParseIP(v); ip != nil {
+				result = append(result, &net.IPAddr{IP: ip})
+				seen[v] = true
+				added++
+			}
+		}
+	}
+	utils.Printf(utils.T("[信息] 已加入 %d 个历史最优 IP，跳过 %d 个黑名单 IP（黑名单共 %d 个）。\n"), added, skipped, len(blacklist))
+	return result
+}
@@ -0,0 +1,39 @@
+package task
+
+import (
+	"DockerST/utils"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestApplyHistory(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	oldBest, oldRuns, oldRetest, oldQuiet, oldScores := HistoryBest, BlacklistRuns, RetestFile, utils.Quiet, utils.HistoryScores
+	t.Cleanup(func() {
+		HistoryBest, BlacklistRuns, RetestFile, utils.Quiet, utils.HistoryScores = oldBest, oldRuns, oldRetest, oldQuiet, oldScores
+	})
+	HistoryBest, BlacklistRuns, utils.Quiet = 1, 1, true
+	run := utils.HistoryRun{
+		ID:      "test",
+		Time:    time.Now(),
+		Results: []utils.ResultRecord{{IP: "1.0.0.9", DownloadMBps: 10}},
+		Tested:  []string{"1.0.0.9"},
+		Failed:  []string{"1.0.0.2"},
+	}
+	if err := utils.AppendHistory(run); err != nil {
+		t.Fatal(err)
+	}
+	ips := []*net.IPAddr{{IP: net.ParseIP("1.0.0.1")}, {IP: net.ParseIP("1.0.0.2")}}
+
+	got := applyHistory(ips)
+	if len(got) != 2 || got[0].String() != "1.0.0.1" || got[1].String() != "1.0.0.9" {
+		t.Errorf("applyHistory() = %v, want [1.0.0.1 1.0.0.9]", got)
+	}
+
+	// 重新测试结果文件时只测试文件中的 IP
+	RetestFile = "result.json"
+	if got := applyHistory(ips); len(got) != 2 || got[0] != ips[0] || got[1] != ips[1] {
+		t.Errorf("applyHistory(-from) = %v, want %v", got, ips)
+	}
+}
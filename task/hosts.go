@@ -0,0 +1,160 @@
+package task
+
+import (
+	"DockerST/utils"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultHostCandidates = 10
+
+// HostCandidates 每个目标域名除 DNS 解析结果外，还测试测速结果的前 N 个 IP
+var HostCandidates = defaultHostCandidates
+
+var (
+	// 解析目标域名的 DNS，测试时替换
+	lookupHost = lookupDNS
+	// 目标域名的 HTTPS 端口
+	hostPort = 443
+)
+
+// TestHosts 为加速器域名以外的每个目标域名（如 registry-1.docker.io）单独选出最优 IP：
+// 候选 IP 为该域名自身的 DNS 解析结果及测速结果中的前 N 个 IP（域名同样使用 Cloudflare 时可能更快），
+// 使用该域名作为 SNI 及 Host 发送 HTTPS 请求，证书有效且有响应的 IP 中平均延迟最低的为最优
+func TestHosts(speedSet utils.DownloadSpeedSet) []utils.HostIP {
+	hosts := utils.ExtraHosts()
+	if len(hosts) == 0 {
+		return nil
+	}
+	if HostCandidates <= 0 {
+		HostCandidates = defaultHostCandidates
+	}
+	utils.Printf(utils.T("\n开始测试目标域名（数量：%d, 候选 IP：DNS 解析结果及测速结果前 %d 个）\n"), len(hosts), HostCandidates)
+	utils.Printf("%-36s%-18s%-10s%-10s\n", utils.T("域名"), utils.T("IP 地址"), utils.T("平均延迟"), utils.T("可用/候选"))
+	var results []utils.HostIP
+	for _, host := range hosts {
+		candidates := hostCandidates(host, speedSet)
+		delays := make([]time.Duration, len(candidates))
+		reasons := make([]string, len(candidates))
+		var wg sync.WaitGroup
+		for i := range candidates {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				delays[i], reasons[i] = hostDelay(host, candidates[i])
+			}(i)
+		}
+		wg.Wait()
+		best, usable := -1, 0
+		for i, delay := range delays {
+			if reasons[i] != "" {
+				continue
+			}
+			usable++
+			if best < 0 || delay < delays[best] {
+				best = i
+			}
+		}
+		count := fmt.Sprintf("%d/%d", usable, len(candidates))
+		if best < 0 {
+			utils.Printf("%-36s%-18s%-10s%-10s%s\n", host, utils.T("无可用 IP"), "", count, summarizeReasons(reasons))
+			continue
+		}
+		result := utils.HostIP{Host: host, IP: candidates[best].String(), Delay: delays[best]}
+		utils.Printf("%-36s%-18s%-10.2f%-10s\n", host, result.IP, float64(result.Delay)/float64(time.Millisecond), count)
+		results = append(results, result)
+	}
+	return results
+}
+
+// 目标域名的候选 IP：DNS 解析结果在前，之后为测速结果的前 HostCandidates 个，去重
+func hostCandidates(host string, speedSet utils.DownloadSpeedSet) []*net.IPAddr {
+	var candidates []*net.IPAddr
+	seen := make(map[string]bool)
+	add := func(ip *net.IPAddr) {
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			candidates = append(candidates, ip)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := lookupHost(ctx, host)
+	if err != nil {
+		utils.Printf(utils.T("[信息] 解析 %s 失败：%v\n"), host, err)
+	}
+	for i := range addrs {
+		add(&addrs[i])
+	}
+	for i := 0; i < len(speedSet) && i < HostCandidates; i++ {
+		add(speedSet[i].IP)
+	}
+	return candidates
+}
+
+// 汇总各候选 IP 的失败原因，如 (cert×8, timeout×2)
+func summarizeReasons(reasons []string) string {
+	counts := make(map[string]int)
+	for _, reason := range reasons {
+		if reason != "" {
+			counts[reason]++
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s×%d", k, counts[k])
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// 通过指定 IP 访问 https://host/，返回平均延迟；证书无效或没有响应时返回失败原因（同 failureReason）
+// 目标域名不一定支持 HTTP/3，始终通过 TCP 连接
+func hostDelay(host string, ip *net.IPAddr) (time.Duration, string) {
+	transport := &http.Transport{DialContext: getDialContext(ip, hostPort), TLSClientConfig: tlsClientConfig.Clone(), ForceAttemptHTTP2: true}
+	hc := http.Client{
+		Timeout:   time.Second * 2,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // 有响应即可，不跟随重定向
+		},
+	}
+	defer hc.CloseIdleConnections()
+
+	var total time.Duration
+	for i := 0; i < PingTimes; i++ {
+		requ, err := http.NewRequest(http.MethodHead, "https://"+host+"/", nil)
+		if err != nil {
+			return 0, failureReason(err)
+		}
+		requ.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
+		startTime := time.Now()
+		resp, err := hc.Do(requ)
+		if err != nil { // 该 IP 不提供该域名的服务（如证书不匹配），不再继续测试
+			return 0, failureReason(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		total += time.Since(startTime)
+	}
+	return total / time.Duration(PingTimes), ""
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"DockerST/utils"
+	"context"
+	"net"
+	"testing"
+)
+
+func TestTestHosts(t *testing.T) {
+	srv := startTLSServer(t) // 证书包含 example.com
+	oldLookup, oldPort, oldTargets, oldQuiet, oldPingTimes := lookupHost, hostPort, utils.TargetHosts, utils.Quiet, PingTimes
+	t.Cleanup(func() {
+		lookupHost, hostPort, utils.TargetHosts, utils.Quiet, PingTimes = oldLookup, oldPort, oldTargets, oldQuiet, oldPingTimes
+	})
+	hostPort = srv.Listener.Addr().(*net.TCPAddr).Port
+	utils.TargetHosts, utils.Quiet, PingTimes = []string{"example.com", "example.org"}, true, 2
+	// example.com 只能通过 DNS 解析结果访问，测速结果中的 IP 不提供该域名的服务
+	lookupHost = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		if host == "example.com" {
+			return []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	speedSet := utils.DownloadSpeedSet{{PingData: &utils.PingData{IP: &net.IPAddr{IP: net.IPv4(127, 0, 0, 2)}}}}
+
+	results := TestHosts(speedSet)
+	if len(results) != 1 || results[0].Host != "example.com" || results[0].IP != "127.0.0.1" || results[0].Delay <= 0 {
+		t.Errorf("TestHosts() = %+v", results)
+	}
+}
+
+func TestHostDelayReason(t *testing.T) {
+	srv := startTLSServer(t)
+	oldPort, oldPingTimes := hostPort, PingTimes
+	t.Cleanup(func() { hostPort, PingTimes = oldPort, oldPingTimes })
+	hostPort, PingTimes = srv.Listener.Addr().(*net.TCPAddr).Port, 1
+
+	ip := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	if delay, reason := hostDelay("example.com", ip); reason != "" || delay <= 0 {
+		t.Errorf("hostDelay(example.com) = %v, %q", delay, reason)
+	}
+	if _, reason := hostDelay("docker.io", ip); reason != "cert" { // 证书不包含该域名
+		t.Errorf("hostDelay(docker.io) reason = %q, want cert", reason)
+	}
+	if _, reason := hostDelay("example.com", &net.IPAddr{IP: net.IPv4(127, 0, 0, 2)}); reason != "refused" {
+		t.Errorf("hostDelay(127.0.0.2) reason = %q, want refused", reason)
+	}
+}
+
+func TestSummarizeReasons(t *testing.T) {
+	got := summarizeReasons([]string{"cert", "", "timeout", "cert", "refused", "timeout", "cert"})
+	if want := "(cert×3, timeout×2, refused×1)"; got != want {
+		t.Errorf("summarizeReasons() = %q, want %q", got, want)
+	}
+	if got := summarizeReasons([]string{"", ""}); got != "" {
+		t.Errorf("summarizeReasons(ok) = %q", got)
+	}
+}
@@ -1,10 +1,11 @@
 package task
 
 import (
+	"DockerST/utils"
 	//"crypto/tls"
 	//"fmt"
+	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"regexp"
@@ -21,73 +22,78 @@ var (
 	OutRegexp         = regexp.MustCompile(`[A-Z]{3}`)
 )
 
-// pingReceived pingTotalTime
-func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration) {
+// 通过头部 Server 值判断是 Cloudflare 还是 AWS CloudFront 并返回各自的机场三字码完整内容
+func cfRayOf(header http.Header) string {
+	if header.Get("Server") == "cloudflare" {
+		return header.Get("CF-RAY") // 示例 cf-ray: 7bd32409eda7b020-SJC
+	}
+	return header.Get("x-amz-cf-pop") // 示例 X-Amz-Cf-Pop: SIN52-P1
+}
+
+func (p *Ping) httping(ip *net.IPAddr) (result probeResult) {
 	hc := http.Client{
-		Timeout: time.Second * 2,
-		Transport: &http.Transport{
-			DialContext: getDialContext(ip),
-			//TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // 跳过证书验证
-		},
+		Timeout:   time.Second * 2,
+		Transport: newTransport(ip, TCPPort),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse // 阻止重定向
 		},
 	}
+	defer hc.CloseIdleConnections()
 
 	// 先访问一次获得 HTTP 状态码 及 Cloudflare Colo
 	{
 		requ, err := http.NewRequest(http.MethodHead, URL, nil)
 		if err != nil {
-			return 0, 0
+			return probeResult{}
 		}
 		requ.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
+		requ, tracer := withTrace(requ)
 		resp, err := hc.Do(requ)
 		if err != nil {
-			return 0, 0
+			utils.AddTrace(ip.String(), "httping", tracer.data(time.Now(), failureReason(err)))
+			return probeResult{}
 		}
 		defer func(Body io.ReadCloser) {
 			_ = Body.Close()
 		}(resp.Body)
+		result.proto = resp.Proto
 
-		//fmt.Println("IP:", ip, "StatusCode:", resp.StatusCode, resp.Request.URL)
+		//utils.Println("IP:", ip, "StatusCode:", resp.StatusCode, resp.Request.URL)
 		// 如果未指定的 HTTP 状态码，或指定的状态码不合规，则默认只认为 200、301、302 才算 HTTPing 通过
 		if HttpingStatusCode == 0 || HttpingStatusCode < 100 && HttpingStatusCode > 599 {
 			if resp.StatusCode != 200 && resp.StatusCode != 301 && resp.StatusCode != 302 {
-				return 0, 0
+				utils.AddTrace(ip.String(), "httping", tracer.data(time.Now(), statusReason(resp.StatusCode)))
+				return probeResult{}
 			}
 		} else {
 			if resp.StatusCode != HttpingStatusCode {
-				return 0, 0
+				utils.AddTrace(ip.String(), "httping", tracer.data(time.Now(), statusReason(resp.StatusCode)))
+				return probeResult{}
 			}
 		}
 
 		_, _ = io.Copy(io.Discard, resp.Body)
+		trace := tracer.data(time.Now(), "")
 
+		result.colo = OutRegexp.FindString(cfRayOf(resp.Header))
 		// 只有指定了地区才匹配机场三字码
 		if HttpingCFColo != "" {
-			// 通过头部 Server 值判断是 Cloudflare 还是 AWS CloudFront 并设置 cfRay 为各自的机场三字码完整内容
-			cfRay := func() string {
-				if resp.Header.Get("Server") == "cloudflare" {
-					return resp.Header.Get("CF-RAY") // 示例 cf-ray: 7bd32409eda7b020-SJC
-				}
-				return resp.Header.Get("x-amz-cf-pop") // 示例 X-Amz-Cf-Pop: SIN52-P1
-			}()
-			colo := p.getColo(cfRay)
+			colo := p.getColo(cfRayOf(resp.Header))
 			if colo == "" { // 没有匹配到三字码或不符合指定地区则直接结束该 IP 测试
-				return 0, 0
+				trace.Failure = "colo"
+				utils.AddTrace(ip.String(), "httping", trace)
+				return probeResult{}
 			}
 		}
-
+		utils.AddTrace(ip.String(), "httping", trace)
 	}
 
 	// 循环测速计算延迟
-	success := 0
-	var delay time.Duration
 	for i := 0; i < PingTimes; i++ {
 		requ, err := http.NewRequest(http.MethodHead, URL, nil)
 		if err != nil {
-			log.Fatal("意外的错误，情报告：", err)
-			return 0, 0
+			utils.Finish(utils.ExitProbeError, fmt.Errorf("%s%w", utils.T("意外的错误，情报告："), err), nil, utils.RunSummary{})
+			return probeResult{}
 		}
 		requ.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/98.0.4758.80 Safari/537.36")
 		if i == PingTimes-1 {
@@ -98,15 +104,12 @@ func (p *Ping) httping(ip *net.IPAddr) (int, time.Duration) {
 		if err != nil {
 			continue
 		}
-		success++
 		_, _ = io.Copy(io.Discard, resp.Body)
 		_ = resp.Body.Close()
-		duration := time.Since(startTime)
-		delay += duration
-
+		result.delays = append(result.delays, time.Since(startTime))
 	}
 
-	return success, delay
+	return
 
 }
 
@@ -1,11 +1,11 @@
 package task
 
 import (
+	"DockerST/utils"
 	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"net"
 	"net/http"
@@ -43,6 +43,13 @@ var (
 	IPText  string
 	randGen *rand.Rand
 	IsOff   bool
+
+	// RetestFile 重新测试之前输出的结果文件（CSV/JSON）中的 IP
+	RetestFile string
+	// RetestTop 重新测试结果文件中的前 N 个 IP，0 为全部
+	RetestTop int
+	// RetestBaseline 结果文件中的原测速结果，用于对比
+	RetestBaseline []utils.ResultRecord
 )
 
 func InitRandSeed() {
@@ -93,7 +100,7 @@ func (r *IPRanges) fixIP(ip string) string {
 func (r *IPRanges) parseCIDR(ip string) {
 	var err error
 	if r.firstIP, r.ipNet, err = net.ParseCIDR(r.fixIP(ip)); err != nil {
-		log.Fatalln("ParseCIDR err", err)
+		utils.Finish(utils.ExitProbeError, fmt.Errorf(utils.T("解析 IP 段 [%s] 失败：%w"), ip, err), nil, utils.RunSummary{})
 	}
 }
 
@@ -171,7 +178,31 @@ func (r *IPRanges) chooseIPv6() {
 	}
 }
 
+// 从之前的结果文件中读取待重新测试的 IP（前 RetestTop 个，0 为全部）
+func loadRetestIPs() []*net.IPAddr {
+	f, err := utils.LoadResult(RetestFile)
+	if err != nil {
+		utils.Finish(utils.ExitProbeError, fmt.Errorf(utils.T("读取结果文件失败：%w"), err), nil, utils.RunSummary{})
+	}
+	records := f.Results
+	if RetestTop > 0 && len(records) > RetestTop {
+		records = records[:RetestTop]
+	}
+	RetestBaseline = records
+	ips := make([]*net.IPAddr, 0, len(records))
+	for _, r := range records {
+		if ip := net.ParseIP(r.IP); ip != nil {
+			ips = append(ips, &net.IPAddr{IP: ip})
+		}
+	}
+	utils.Printf(utils.T("[信息] 从结果文件 %s 读取 %d 个 IP 进行重新测试。\n"), RetestFile, len(ips))
+	return ips
+}
+
 func loadIPRanges() []*net.IPAddr {
+	if RetestFile != "" { // 重新测试之前的结果，不再随机选取 IP
+		return loadRetestIPs()
+	}
 	ranges := newIPRanges()
 	if IPText != "" { // 从参数中获取 IP 段数据
 		IPs := strings.Split(IPText, ",") // 以逗号分隔为数组并循环遍历
@@ -198,7 +229,7 @@ func loadIPRanges() []*net.IPAddr {
 		defer func(file *os.File) {
 			err = file.Close()
 			if err != nil {
-				log.Fatal(err)
+				utils.Finish(utils.ExitProbeError, err, nil, utils.RunSummary{})
 			}
 		}(file)
 		scanner := bufio.NewScanner(file)
@@ -238,7 +269,7 @@ func ToNetAddr() []*net.IPAddr {
 	// 获取在线IPv4 CIDR列表
 	resp, err := http.Get(IPCidrApi)
 	if err != nil {
-		fmt.Println("获取在线列表失败，正在使用内置列表")
+		utils.Println(utils.T("获取在线列表失败，正在使用内置列表"))
 		return ranges.ips
 	}
 	defer func(Body io.ReadCloser) {
@@ -248,7 +279,7 @@ func ToNetAddr() []*net.IPAddr {
 	// 读取响应主体
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("获取在线列表失败，正在使用内置列表")
+		utils.Println(utils.T("获取在线列表失败，正在使用内置列表"))
 		return ranges.ips
 	}
 
@@ -261,11 +292,11 @@ func ToNetAddr() []*net.IPAddr {
 	}
 
 	if err = json.Unmarshal(body, &data); err != nil || !data.Success {
-		fmt.Println("获取在线列表失败，正在使用内置列表")
+		utils.Println(utils.T("获取在线列表失败，正在使用内置列表"))
 		return ranges.ips
 	}
 
-	fmt.Println("获取在线列表成功，正在使用在线列表")
+	utils.Println(utils.T("获取在线列表成功，正在使用在线列表"))
 	ranges = newIPRanges()
 	for _, v := range data.Result.IPv4CIDRs {
 		line := strings.TrimSpace(v)
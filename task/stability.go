@@ -0,0 +1,144 @@
+package task
+
+import (
+	"DockerST/utils"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultStableRounds   = 3
+	defaultStableInterval = 60 * time.Second
+)
+
+var (
+	// StableTop 稳定性测试的 IP 数量（下载测速结果的前 N 个），0 为不进行稳定性测试
+	StableTop int
+	// StableRounds 稳定性测试轮数
+	StableRounds = defaultStableRounds
+	// StableInterval 每轮稳定性测试之间的间隔
+	StableInterval = defaultStableInterval
+	// StablePercentile 按该百分位的下载速度排名，0 为最差值（最低速度）
+	StablePercentile float64
+)
+
+func checkStableDefault() {
+	if StableRounds <= 0 {
+		StableRounds = defaultStableRounds
+	}
+	if StableInterval < 0 {
+		StableInterval = defaultStableInterval
+	}
+	if StablePercentile < 0 || StablePercentile > 100 {
+		StablePercentile = 0
+	}
+}
+
+// 取样本的第 p 百分位（0 为最小值，100 为最大值）
+func percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	i := int(math.Round(p / 100 * float64(len(sorted)-1)))
+	return sorted[i]
+}
+
+// 稳定延迟的列名：按最差值排名时为最差延迟，否则为对应的百分位（速度的第 p 百分位对应延迟的第 100-p 百分位）
+func stableDelayHeader() string {
+	if StablePercentile == 0 {
+		return utils.T("最差延迟")
+	}
+	return fmt.Sprintf(utils.T("P%.0f 延迟"), 100-StablePercentile)
+}
+
+// TestStability 在一段时间内多次重复测试下载测速结果中的前 N 个 IP（下载 + 延迟），
+// 按最差值或指定百分位的下载速度重新排名，其余 IP 保持原顺序排在后面
+func TestStability(speedSet utils.DownloadSpeedSet) utils.DownloadSpeedSet {
+	if StableTop <= 0 || len(speedSet) == 0 {
+		return speedSet
+	}
+	if Disable {
+		utils.Println(utils.T("\n[信息] 已禁用下载测速，跳过稳定性测试。"))
+		return speedSet
+	}
+	checkStableDefault()
+	n := StableTop
+	if n > len(speedSet) {
+		n = len(speedSet)
+	}
+	utils.Printf(utils.T("\n开始稳定性测试（数量：%d, 轮数：%d, 间隔：%v, 百分位：P%.0f）\n"), n, StableRounds, StableInterval, StablePercentile)
+
+	// 第一轮样本即为下载测速的结果
+	speeds := make([][]float64, n)
+	delays := make([][]float64, n)
+	sended := make([]int, n)
+	received := make([]int, n)
+	for i := 0; i < n; i++ {
+		speeds[i] = []float64{speedSet[i].DownloadSpeed}
+		delays[i] = []float64{float64(speedSet[i].Delay)}
+		sended[i], received[i] = speedSet[i].Sended, speedSet[i].Received
+	}
+
+	live := utils.NewLiveView(n * (StableRounds - 1))
+	live.Header()
+	probe := &Ping{}
+stop:
+	for round := 1; round < StableRounds; round++ {
+		if StableInterval > 0 {
+			utils.Printf(utils.T("[信息] 等待 %v 后开始第 %d/%d 轮稳定性测试...\n"), StableInterval, round+1, StableRounds)
+			time.Sleep(StableInterval)
+		}
+		for i := 0; i < n; i++ {
+			limit := nextByteLimit()
+			if limit < 0 {
+				utils.Printf(utils.T("\n[信息] 已达到下载测速总流量上限（%.2f MB），停止稳定性测试。\n"), float64(MaxTotalBytes)/1024/1024)
+				break stop
+			}
+			ip := speedSet[i].IP.String()
+			result := downloadHandler(speedSet[i].IP, limit, func(current, average float64, read int64, remain time.Duration) {
+				live.Update(ip, current, average, read, remain)
+			})
+			TotalBytes += result.bytes
+			speeds[i] = append(speeds[i], result.speed)
+
+			samples := probe.checkConnection(speedSet[i].IP).delays
+			sended[i] += PingTimes
+			received[i] += len(samples)
+			if len(samples) > 0 {
+				delays[i] = append(delays[i], float64(sumDelay(samples)/time.Duration(len(samples))))
+			}
+
+			sample := speedSet[i]
+			sample.DownloadSpeed, sample.DownloadBytes, sample.Trace = result.speed, result.bytes, result.trace
+			live.Done(sample, result.speed >= MinSpeed*1024*1024)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		speedSet[i].StableSpeed = percentile(speeds[i], StablePercentile)
+		speedSet[i].StableDelay = time.Duration(percentile(delays[i], 100-StablePercentile))
+		speedSet[i].StableSamples = len(speeds[i])
+		if sended[i] > 0 {
+			speedSet[i].StableLossRate = float32(sended[i]-received[i]) / float32(sended[i])
+		}
+	}
+	top := speedSet[:n]
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].StableSpeed != top[j].StableSpeed {
+			return top[i].StableSpeed > top[j].StableSpeed
+		}
+		return top[i].StableDelay < top[j].StableDelay
+	})
+
+	utils.Printf("\n%-18s%-8s%-16s%-14s%-8s\n", utils.T("IP 地址"), utils.T("样本"), utils.T("稳定速度 (MB/s)"), stableDelayHeader(), utils.T("丢包率"))
+	for _, v := range top {
+		utils.Printf("%-18s%-8d%-16.2f%-14s%-8.2f\n", v.IP.String(), v.StableSamples, v.StableSpeed/1024/1024,
+			fmt.Sprintf("%.0fms", v.StableDelay.Seconds()*1000), v.StableLossRate)
+	}
+	utils.Printf(utils.T("[信息] 下载测速及稳定性测试共消耗流量：%.2f MB\n"), float64(TotalBytes)/1024/1024)
+	return speedSet
+}
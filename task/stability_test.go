@@ -0,0 +1,79 @@
+package task
+
+import (
+	"DockerST/utils"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := []float64{5, 1, 4, 2, 3}
+	tests := []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{90, 5},
+		{100, 5},
+		{25, 2},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples, tt.p); got != tt.want {
+			t.Errorf("percentile(P%.0f) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v", got)
+	}
+	if samples[0] != 5 {
+		t.Error("percentile() 修改了原样本")
+	}
+}
+
+func TestStableDelayHeader(t *testing.T) {
+	old := StablePercentile
+	defer func() { StablePercentile = old }()
+	StablePercentile = 0
+	if got := stableDelayHeader(); got != "最差延迟" {
+		t.Errorf("stableDelayHeader() = %q", got)
+	}
+	// 速度的第 10 百分位对应延迟的第 90 百分位
+	StablePercentile = 10
+	if got := stableDelayHeader(); got != "P90 延迟" {
+		t.Errorf("stableDelayHeader(P10) = %q, want P90 延迟", got)
+	}
+}
+
+func TestStabilityResort(t *testing.T) {
+	srv := startTLSServer(t)
+	setDownloadParams(t, "h1", srv.Listener.Addr().(*net.TCPAddr).Port)
+	oldTop, oldRounds, oldInterval, oldPercentile, oldTotal, oldQuiet := StableTop, StableRounds, StableInterval, StablePercentile, TotalBytes, utils.Quiet
+	t.Cleanup(func() {
+		StableTop, StableRounds, StableInterval, StablePercentile, TotalBytes, utils.Quiet = oldTop, oldRounds, oldInterval, oldPercentile, oldTotal, oldQuiet
+	})
+	StableTop, StableRounds, StableInterval, StablePercentile, utils.Quiet = 2, 2, 0, 0, true
+
+	data := func(speed float64) utils.CloudflareIPData {
+		return utils.CloudflareIPData{
+			PingData:      &utils.PingData{IP: &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, Sended: 4, Received: 4, Delay: time.Millisecond},
+			DownloadSpeed: speed,
+		}
+	}
+	// 本地下载速度远高于第一轮的速度，最差值即为第一轮的速度
+	speedSet := utils.DownloadSpeedSet{data(2), data(3), data(1)}
+	got := TestStability(speedSet)
+	if got[0].DownloadSpeed != 3 || got[1].DownloadSpeed != 2 || got[2].DownloadSpeed != 1 {
+		t.Errorf("排名 = %v, %v, %v, want 3, 2, 1", got[0].DownloadSpeed, got[1].DownloadSpeed, got[2].DownloadSpeed)
+	}
+	if got[0].StableSpeed != 3 || got[0].StableSamples != 2 {
+		t.Errorf("StableSpeed = %v, StableSamples = %d", got[0].StableSpeed, got[0].StableSamples)
+	}
+	// 前 N 个以外的 IP 不参与稳定性测试
+	if got[2].StableSamples != 0 {
+		t.Errorf("got[2].StableSamples = %d, want 0", got[2].StableSamples)
+	}
+	if TotalBytes < 2*testBodySize {
+		t.Errorf("TotalBytes = %d, want >= %d", TotalBytes, 2*testBodySize)
+	}
+}
@@ -22,6 +22,9 @@ var (
 	Routines      = defaultRoutines
 	TCPPort   int = defaultPort
 	PingTimes int = defaultPingTimes
+
+	// FailedIPs 最近一次延迟测速中全部丢包（不可用）的 IP
+	FailedIPs []*net.IPAddr
 )
 
 type Ping struct {
@@ -29,6 +32,7 @@ type Ping struct {
 	m       *sync.Mutex
 	ips     []*net.IPAddr
 	csv     utils.PingDelaySet
+	failed  []*net.IPAddr
 	control chan bool
 	bar     *utils.Bar
 }
@@ -47,14 +51,14 @@ func checkPingDefault() {
 
 func NewPing() *Ping {
 	checkPingDefault()
-	ips := loadIPRanges()
+	ips := applyHistory(loadIPRanges())
 	return &Ping{
 		wg:      &sync.WaitGroup{},
 		m:       &sync.Mutex{},
 		ips:     ips,
 		csv:     make(utils.PingDelaySet, 0),
 		control: make(chan bool, Routines),
-		bar:     utils.NewBar(len(ips), "可用:", ""),
+		bar:     utils.NewBar(len(ips), utils.T("可用:"), ""),
 	}
 }
 
@@ -63,9 +67,9 @@ func (p *Ping) Run() utils.PingDelaySet {
 		return p.csv
 	}
 	if Httping {
-		fmt.Printf("开始延迟测速（模式：HTTP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
+		utils.Printf(utils.T("开始延迟测速（模式：HTTP, 协议：%s, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n"), Protocol, TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
 	} else {
-		fmt.Printf("开始延迟测速（模式：TCP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n", TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
+		utils.Printf(utils.T("开始延迟测速（模式：TCP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n"), TCPPort, utils.InputMinDelay.Milliseconds(), utils.InputMaxDelay.Milliseconds(), utils.InputMaxLossRate)
 	}
 	for _, ip := range p.ips {
 		p.wg.Add(1)
@@ -74,6 +78,7 @@ func (p *Ping) Run() utils.PingDelaySet {
 	}
 	p.wg.Wait()
 	p.bar.Done()
+	FailedIPs = p.failed
 	sort.Sort(p.csv)
 	return p.csv
 }
@@ -102,21 +107,49 @@ func (p *Ping) tcping(ip *net.IPAddr) (bool, time.Duration) {
 	return true, duration
 }
 
-// pingReceived pingTotalTime
-func (p *Ping) checkConnection(ip *net.IPAddr) (recv int, totalDelay time.Duration) {
+// 单个 IP 的延迟测速结果
+type probeResult struct {
+	delays []time.Duration // 每次成功连接的延迟
+	proto  string          // HTTP 测速实际协商的协议
+	colo   string          // HTTP 测速响应头中的地区三字码
+}
+
+func (p *Ping) checkConnection(ip *net.IPAddr) (result probeResult) {
 	if Httping {
-		recv, totalDelay = p.httping(ip)
-		return
+		return p.httping(ip)
 	}
 	for i := 0; i < PingTimes; i++ {
 		if ok, delay := p.tcping(ip); ok {
-			recv++
-			totalDelay += delay
+			result.delays = append(result.delays, delay)
 		}
 	}
 	return
 }
 
+// 总延迟
+func sumDelay(delays []time.Duration) (total time.Duration) {
+	for _, d := range delays {
+		total += d
+	}
+	return
+}
+
+// 抖动：相邻两次延迟之差的平均值
+func jitterOf(delays []time.Duration) time.Duration {
+	if len(delays) < 2 {
+		return 0
+	}
+	var total time.Duration
+	for i := 1; i < len(delays); i++ {
+		diff := delays[i] - delays[i-1]
+		if diff < 0 {
+			diff = -diff
+		}
+		total += diff
+	}
+	return total / time.Duration(len(delays)-1)
+}
+
 func (p *Ping) appendIPData(data *utils.PingData) {
 	p.m.Lock()
 	defer p.m.Unlock()
@@ -127,13 +160,17 @@ func (p *Ping) appendIPData(data *utils.PingData) {
 
 // handle tcping
 func (p *Ping) tcpingHandler(ip *net.IPAddr) {
-	recv, totalDlay := p.checkConnection(ip)
+	result := p.checkConnection(ip)
+	recv, totalDlay := len(result.delays), sumDelay(result.delays)
 	nowAble := len(p.csv)
 	if recv != 0 {
 		nowAble++
 	}
 	p.bar.Grow(1, strconv.Itoa(nowAble))
 	if recv == 0 {
+		p.m.Lock()
+		p.failed = append(p.failed, ip)
+		p.m.Unlock()
 		return
 	}
 	data := &utils.PingData{
@@ -141,6 +178,9 @@ func (p *Ping) tcpingHandler(ip *net.IPAddr) {
 		Sended:   PingTimes,
 		Received: recv,
 		Delay:    totalDlay / time.Duration(recv),
+		Jitter:   jitterOf(result.delays),
+		Protocol: result.proto,
+		Colo:     result.colo,
 	}
 	p.appendIPData(data)
 }
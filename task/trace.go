@@ -0,0 +1,105 @@
+package task
+
+import (
+	"DockerST/utils"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 记录单次 HTTP 请求各阶段的时间点
+// httptrace 的回调可能在 Transport 的其他 goroutine 中执行（如 HTTP/2 连接），读写时加锁
+type phaseTracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+}
+
+// 为请求挂载 httptrace，返回新的请求
+func withTrace(req *http.Request) (*http.Request, *phaseTracer) {
+	t := &phaseTracer{start: time.Now()}
+	trace := &httptrace.ClientTrace{
+		GetConn:              func(string) { t.reset() },
+		ConnectStart:         func(network, addr string) { t.mark(&t.connectStart) },
+		ConnectDone:          func(network, addr string, err error) { t.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.mark(&t.tlsDone) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+func (t *phaseTracer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+// 每次请求（包括重定向后的请求）获取连接时重新开始记录，各阶段耗时以最后一次请求为准
+func (t *phaseTracer) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start = time.Now()
+	t.connectStart, t.connectDone, t.tlsStart, t.tlsDone, t.firstByte = time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}
+}
+
+// 按已记录的时间点计算各阶段耗时，failure 为空代表请求成功
+func (t *phaseTracer) data(end time.Time, failure string) *utils.TraceData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := &utils.TraceData{Failure: failure}
+	if !t.connectStart.IsZero() && !t.connectDone.IsZero() {
+		d.Connect = t.connectDone.Sub(t.connectStart)
+	}
+	if !t.tlsStart.IsZero() && !t.tlsDone.IsZero() {
+		d.TLS = t.tlsDone.Sub(t.tlsStart)
+	}
+	if !t.firstByte.IsZero() {
+		d.TTFB = t.firstByte.Sub(t.start)
+		if end.After(t.firstByte) {
+			d.Transfer = end.Sub(t.firstByte)
+		}
+	}
+	return d
+}
+
+// 将请求错误归类为简短的失败原因
+func failureReason(err error) string {
+	var (
+		netErr       net.Error
+		unknownAuth  x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		certInvalid  x509.CertificateInvalidError
+		verification *tls.CertificateVerificationError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.As(err, &unknownAuth), errors.As(err, &hostnameErr), errors.As(err, &certInvalid), errors.As(err, &verification):
+		return "cert"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// 状态码不符合要求时的失败原因
+func statusReason(code int) string {
+	return "status " + strconv.Itoa(code)
+}
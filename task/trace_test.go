@@ -0,0 +1,83 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTraceRedirect(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" { // 重定向前等待一段时间，并关闭连接，重定向后的请求使用新连接
+			time.Sleep(200 * time.Millisecond)
+			w.Header().Set("Connection", "close")
+			http.Redirect(w, r, "/file", http.StatusFound)
+			return
+		}
+		testHandler(w, r)
+	}))
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	oldConfig := tlsClientConfig
+	tlsClientConfig = &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	t.Cleanup(func() { tlsClientConfig = oldConfig })
+	setDownloadParams(t, "h1", srv.Listener.Addr().(*net.TCPAddr).Port)
+	URL = "https://example.com/old"
+
+	result := downloadHandler(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, 0, nil)
+	if result.trace == nil || result.trace.Failure != "" || result.bytes != testBodySize {
+		t.Fatalf("downloadHandler() = %+v", result)
+	}
+	// 各阶段耗时为重定向后的请求，不包含重定向前的等待
+	if d := result.trace; d.Connect <= 0 || d.Connect > 100*time.Millisecond || d.TTFB > 150*time.Millisecond {
+		t.Errorf("trace = %+v, want timings of the redirected request", d)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	srv := startTLSServer(t)
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	_ = closed.Close()
+
+	request := func(host string, port int, timeout time.Duration) error {
+		client := &http.Client{
+			Transport: newTransport(&net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}, port),
+			Timeout:   timeout,
+		}
+		defer client.CloseIdleConnections()
+		resp, err := client.Get("https://" + host + "/")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"ok", nil, ""},
+		{"refused", request("example.com", closedPort, time.Second), "refused"},
+		{"cert", request("docker.io", port, time.Second), "cert"},
+		{"timeout", context.DeadlineExceeded, "timeout"},
+		{"other", http.ErrNotSupported, "error"},
+	}
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%s: %v) = %q, want %q", tt.name, tt.err, got, tt.want)
+		}
+	}
+	if got := statusReason(http.StatusForbidden); got != "status 403" {
+		t.Errorf("statusReason(403) = %q", got)
+	}
+}
@@ -2,84 +2,159 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	DefaultDockerUrl = "http://docker.sxh.workers.dev"
+	// DockerConfigPath 指定的 Docker 配置文件路径，为空时自动检测（rootless Docker、dockerd 的 --config-file 等）
+	DockerConfigPath string
+	// MirrorMode 加速器地址写入 registry-mirrors 的方式：prepend（放在最前）、append（放在最后）、replace（替换全部）
+	MirrorMode = "prepend"
+	// TargetHosts 需要写入 hosts 的其他目标域名（如 registry-1.docker.io），加速器域名总是会写入
+	TargetHosts []string
+	// HostIPs 各目标域名的最优 IP，由 task.TestHosts 得到
+	HostIPs []HostIP
+
+	// DryRun 演练模式：完成测速后只输出将要修改的文件差异及将要执行的命令，不修改任何文件，
+	// 也不写入结果文件、历史记录、指标文件及锁文件
+	DryRun bool
+
+	// AppliedIP 本次运行写入 hosts 的 IP，为空代表未写入
+	AppliedIP   string
+	appliedData *CloudflareIPData
 )
 
-func (s DownloadSpeedSet) DockerSet() {
-	// 选择最优的节点
-	bestIP := s[0].IP
-	bestSpeed := convertToString(s)[0][5]
-	// 自动优选节点 最高速度为 0 时，不进行优选
-	if bestSpeed == "0" {
-		fmt.Println("\n[信息] 未找到最优节点，跳过优选节点。")
-		return
-	} else {
-		fmt.Println("\n[信息] 最优节点：", bestIP, " 速度：", bestSpeed, "MB/s")
+// HostIP 目标域名的最优 IP
+type HostIP struct {
+	Host  string
+	IP    string
+	Delay time.Duration
+}
+
+// ExtraHosts 返回加速器域名以外的目标域名（去重）
+func ExtraHosts() []string {
+	var hosts []string
+	for _, host := range TargetHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" && host != DockerDomain() && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// DockerDomain 返回 Docker 地址中的域名
+func DockerDomain() string {
+	return strings.Split(strings.Split(DefaultDockerUrl, "//")[1], "/")[0]
+}
+
+// DockerSet 将最优 IP 写入 hosts 并设置 Docker 加速器，没有可用 IP 时返回 ErrNoUsableIP
+// 写入后验证修改是否生效，未通过时自动还原并返回 ErrVerifyFailed
+func (s DownloadSpeedSet) DockerSet() error {
+	if len(s) == 0 {
+		Println(T("\n[信息] 未找到最优节点，跳过优选节点。"))
+		return ErrNoUsableIP
+	}
+	// 选择最优的节点：第一个可用的节点，进行了下载测速且速度为 0 的节点不可用
+	best := -1
+	for i := range s {
+		if !s[i].downloadFailed() {
+			best = i
+			break
+		}
+	}
+	if best < 0 {
+		Println(T("\n[信息] 未找到最优节点，跳过优选节点。"))
+		return ErrNoUsableIP
 	}
+	bestIP := s[best].IP
+	bestSpeed := s[best].toString()[5]
+	Println(T("\n[信息] 最优节点："), bestIP, T(" 速度："), bestSpeed, "MB/s")
 	// 输出结果
-	fmt.Println("\n[信息] 开始写入 hosts 文件...")
-	err := WriteHosts(bestIP.String())
+	if DryRun {
+		Println(T("\n[演练] 以下为将要进行的修改，不会修改任何文件："))
+	} else {
+		Println(T("\n[信息] 开始写入 hosts 文件..."))
+	}
+	err := WriteHosts(bestIP.String(), HostIPs)
 	if err != nil {
-		fmt.Println("\n[错误] 写入 hosts 文件失败：", err)
-		return
+		Println(T("\n[错误] 写入 hosts 文件失败："), err)
+		return fmt.Errorf(T("写入 hosts 文件失败：%w"), err)
+	}
+	if !DryRun {
+		AppliedIP, appliedData = bestIP.String(), &s[best]
 	}
 
 	err = SetDockerAccelerator(DefaultDockerUrl)
+	if DryRun || NoVerify || err != nil && restartedRuntime == "" {
+		if err != nil {
+			Println(T("\n[错误] 设置 Docker 加速器失败："), err)
+		}
+		return err
+	}
+	// 重启后服务未能恢复时直接还原，否则先验证修改是否生效
+	if err == nil {
+		err = VerifyApplied(DefaultDockerUrl)
+	}
 	if err != nil {
-		fmt.Println("\n[错误] 设置 Docker 加速器失败：", err)
+		Println(T("\n[错误] 验证失败："), err)
+		Println(T("\n[信息] 正在还原本次修改..."))
+		if rollbackErr := RollbackApplied(); rollbackErr != nil {
+			Println(T("\n[错误] 还原失败："), rollbackErr)
+			return fmt.Errorf(T("还原本次修改失败: %w"), rollbackErr)
+		}
+		return fmt.Errorf("%w: %w", ErrVerifyFailed, err)
 	}
+	return nil
 }
 
-func WriteHosts(bestIP string) error {
-	// 获取当前系统
-	system := runtime.GOOS
-	switch system {
-	case "windows":
-		return writeHostsWindows(bestIP)
-	case "darwin":
-		return writeHostsMac(bestIP)
-	case "linux":
-		return writeHostsLinux(bestIP)
-	default:
-		return fmt.Errorf("不支持的操作系统：%s", system)
+// WriteHosts 将加速器域名的最优 IP 及其他目标域名各自的最优 IP 写入 hosts
+func WriteHosts(bestIP string, hostIPs []HostIP) error {
+	hostsFilePath, err := hostsPath()
+	if err != nil {
+		return err
 	}
+	entries := []string{bestIP + " " + DockerDomain()}
+	for _, h := range hostIPs {
+		entries = append(entries, h.IP+" "+h.Host)
+	}
+	return writeHostsFile(hostsFilePath, entries)
 }
 
-func writeHostsWindows(bestIP string) error {
-	hostsFilePath := "C:\\Windows\\System32\\drivers\\etc\\hosts"
-	return writeHostsFile(hostsFilePath, bestIP)
-}
-
-func writeHostsMac(bestIP string) error {
-	hostsFilePath := "/etc/hosts"
-	return writeHostsFile(hostsFilePath, bestIP)
-}
-
-func writeHostsLinux(bestIP string) error {
-	hostsFilePath := "/etc/hosts"
-	return writeHostsFile(hostsFilePath, bestIP)
+// 返回当前系统的 hosts 文件路径
+func hostsPath() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return "C:\\Windows\\System32\\drivers\\etc\\hosts", nil
+	case "darwin", "linux":
+		return "/etc/hosts", nil
+	default:
+		return "", fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
+	}
 }
 
-func writeHostsFile(hostsFilePath, bestIP string) error {
-	// 检查文件权限
-	file, err := os.OpenFile(hostsFilePath, os.O_RDWR, 0644)
+// 读取 hosts 文件内容，去掉 DockerST 写入的部分
+func readHostsLines(hostsFilePath string) ([]string, error) {
+	file, err := os.Open(hostsFilePath)
 	if err != nil {
-		return fmt.Errorf("无法打开 hosts 文件: %w", err)
+		return nil, fmt.Errorf(T("无法打开 hosts 文件: %w"), err)
 	}
 	defer func(file *os.File) {
 		_ = file.Close()
 	}(file)
 
-	// 读取文件内容
 	var lines []string
 	scanner := bufio.NewScanner(file)
 	inBlock := false
@@ -96,33 +171,77 @@ func writeHostsFile(hostsFilePath, bestIP string) error {
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("读取 hosts 文件出错: %w", err)
+		return nil, fmt.Errorf(T("读取 hosts 文件出错: %w"), err)
 	}
+	return lines, nil
+}
 
-	// 重写文件内容
-	file, err = os.Create(hostsFilePath)
+// 读取 hosts 文件中 DockerST 写入部分的记录（IP 及域名）
+func managedHostsEntries(hostsFilePath string) ([]HostIP, error) {
+	content, err := os.ReadFile(hostsFilePath)
 	if err != nil {
-		return fmt.Errorf("无法重写 hosts 文件: %w", err)
+		return nil, err
 	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
+	var entries []HostIP
+	inBlock := false
+	for _, line := range strings.Split(string(content), "\n") {
+		switch {
+		case strings.Contains(line, "# DockerST Start"):
+			inBlock = true
+		case strings.Contains(line, "# DockerST End"):
+			inBlock = false
+		case inBlock:
+			entries = append(entries, parseHostsEntries(line)...)
+		}
+	}
+	return entries, nil
+}
 
-	writer := bufio.NewWriter(file)
+// 解析 "IP 域名" 形式的 hosts 记录，多条记录以 ; 分隔（备份记录中的修改说明），不是记录的部分忽略
+func parseHostsEntries(s string) []HostIP {
+	var entries []HostIP
+	for _, item := range strings.Split(s, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 2 && net.ParseIP(fields[0]) != nil {
+			entries = append(entries, HostIP{Host: fields[1], IP: fields[0]})
+		}
+	}
+	return entries
+}
+
+// 将内容重写到 hosts 文件，block 为 DockerST 写入的行（为空则不写入 DockerST 部分），change 为备份记录中的修改说明
+func rewriteHostsFile(hostsFilePath string, lines, block []string, change string) error {
+	var b strings.Builder
 	for _, line := range lines {
-		_, _ = fmt.Fprintln(writer, line)
+		b.WriteString(line + "\n")
+	}
+	if len(block) > 0 {
+		b.WriteString("# DockerST Start\n")
+		for _, line := range block {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("# DockerST End\n")
 	}
-	_, _ = fmt.Fprintln(writer, "# DockerST Start")
-	domain := strings.Split(strings.Split(DefaultDockerUrl, "//")[1], "/")[0]
-	_, _ = fmt.Fprintln(writer, bestIP+" "+domain)
-	_, _ = fmt.Fprintln(writer, "# DockerST End")
 
-	err = writer.Flush()
+	if err := applyFile(BackupHosts, hostsFilePath, change, []byte(b.String())); err != nil {
+		return fmt.Errorf(T("写入 hosts 文件出错: %w"), err)
+	}
+	return nil
+}
+
+func writeHostsFile(hostsFilePath string, entries []string) error {
+	lines, err := readHostsLines(hostsFilePath)
 	if err != nil {
-		return fmt.Errorf("写入 hosts 文件出错: %w", err)
+		return err
+	}
+	if err = rewriteHostsFile(hostsFilePath, lines, entries, strings.Join(entries, "; ")); err != nil {
+		return err
+	}
+	if DryRun {
+		return nil
 	}
 
-	fmt.Println("\n[信息] 成功写入 hosts 文件。")
+	Println(T("\n[信息] 成功写入 hosts 文件。"))
 	return nil
 }
 
@@ -133,109 +252,271 @@ func isDockerInstalled() bool {
 	return err == nil
 }
 
+// SetDockerAccelerator 将加速器地址写入容器运行时（Docker、containerd 等）的配置
 func SetDockerAccelerator(dockerUrl string) error {
-	if !isDockerInstalled() {
-		return fmt.Errorf("docker 未安装")
+	rt, err := SelectRuntime()
+	if err != nil {
+		return err
+	}
+	configPath, err := rt.ConfigPath()
+	if err == nil {
+		err = rt.SetMirror(configPath, dockerUrl)
 	}
 
-	system := runtime.GOOS
-	var err error
+	if err != nil {
+		return fmt.Errorf(T("设置 Docker 加速器失败: %w"), err)
+	}
+	cmd, _ := rt.RestartCommand()
+	if DryRun { // 演练模式下只输出将要执行的命令
+		if cmd != nil && RestartPolicy != "never" {
+			Println(T("\n[演练] 确认重启服务后将执行："), cmd.String())
+		}
+		return nil
+	}
+	Printf(T("\n[信息] %s 加速器已设置为：%s\n"), rt.Name(), dockerUrl)
+	if cmd == nil {
+		return nil
+	}
+	switch {
+	case RestartPolicy == "always":
+		return RestartRuntime(rt.Name())
+	case RestartPolicy == "ask" && !Quiet && isTerminal(os.Stdin): // 没有终端时（如 cron）不询问，避免一直等待输入
+		Printf(T("\n[提示] 是否重启 %s 服务？(y/n): "), rt.Name())
+		var input string
+		_, _ = fmt.Scanln(&input)
+		if strings.ToLower(input) == "y" {
+			return RestartRuntime(rt.Name())
+		}
+		return nil
+	}
+	Printf(T("\n[提示] 需要重启 %s 服务才能生效（可使用 -restart always 参数自动重启）：%s\n"), rt.Name(), cmd)
+	return nil
+}
 
-	switch system {
+// 返回重启 Docker 服务的命令
+func restartCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
 	case "windows":
-		err = setDockerAcceleratorWindows(dockerUrl)
+		return exec.Command("powershell", "Restart-Service", "docker"), nil
 	case "darwin":
-		err = setDockerAcceleratorMac(dockerUrl)
+		return exec.Command("brew", "services", "restart", "docker"), nil
 	case "linux":
-		err = setDockerAcceleratorLinux(dockerUrl)
+		rootless := isRootlessDocker()
+		if hasSystemd() {
+			if rootless { // rootless Docker 由当前用户的 systemd 服务管理
+				return exec.Command("systemctl", "--user", "restart", "docker"), nil
+			}
+			return exec.Command("systemctl", "restart", "docker"), nil
+		}
+		// 没有 systemd 时向 dockerd 发送 SIGHUP，dockerd 会重新加载 registry-mirrors 等配置
+		if pid := dockerdPid(rootless); pid > 0 {
+			return exec.Command("kill", "-HUP", strconv.Itoa(pid)), nil
+		}
+		return exec.Command("sudo", "service", "docker", "restart"), nil
 	default:
-		err = fmt.Errorf("不支持的操作系统：%s", system)
+		return nil, fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
 	}
+}
 
+// 系统是否由 systemd 管理
+func hasSystemd() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+// 读取 dockerd 的 pid 文件：/var/run/docker.pid，rootless Docker 为 $XDG_RUNTIME_DIR/docker.pid，读取失败时返回 0
+func dockerdPid(rootless bool) int {
+	pidFile := "/var/run/docker.pid"
+	if rootless {
+		pidFile = filepath.Join(filepath.Dir(rootlessDockerSocket()), "docker.pid")
+	}
+	content, err := os.ReadFile(pidFile)
 	if err != nil {
-		return fmt.Errorf("设置 Docker 加速器失败: %w", err)
-	}
-	fmt.Println("\n[信息] Docker 加速器已设置为：", dockerUrl)
-	// 询问是否重启 Docker 服务
-	fmt.Print("\n[提示] 是否重启 Docker 服务？(y/n): ")
-	var input string
-	_, _ = fmt.Scanln(&input)
-	if strings.ToLower(input) == "y" {
-		err = RestartDocker()
-		if err != nil {
-			return fmt.Errorf("重启 Docker 服务失败: %w", err)
-		}
+		return 0
 	}
-	return nil
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+	return pid
 }
 
-func RestartDocker() error {
-	var cmd *exec.Cmd
+// 返回当前系统的 Docker 配置文件路径：-docker-config 参数指定的路径、正在运行的 dockerd 的 --config-file 参数、默认路径
+func dockerConfigPath() (string, error) {
+	if DockerConfigPath != "" {
+		return DockerConfigPath, nil
+	}
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("powershell", "Restart-Service", "docker")
+		return os.Getenv("USERPROFILE") + "\\.docker\\daemon.json", nil
 	case "darwin":
-		cmd = exec.Command("brew", "services", "restart", "docker")
+		return os.Getenv("HOME") + "/.docker/daemon.json", nil
 	case "linux":
-		cmd = exec.Command("sudo", "service", "docker", "restart")
+		rootless := isRootlessDocker()
+		if path := runningDockerdConfig(rootless); path != "" {
+			return path, nil
+		}
+		if !rootless {
+			return "/etc/docker/daemon.json", nil
+		}
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".config")
+		}
+		return filepath.Join(dir, "docker", "daemon.json"), nil
 	default:
-		return fmt.Errorf("不支持的操作系统：%s", runtime.GOOS)
+		return "", fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
 	}
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return fmt.Errorf("重启 Docker 服务失败: %w", err)
+// 返回 rootless Docker 的 socket 路径：$XDG_RUNTIME_DIR/docker.sock
+func rootlessDockerSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fmt.Sprintf("/run/user/%d", os.Getuid())
 	}
-
-	fmt.Println("Docker 服务已重启。")
-	return nil
+	return filepath.Join(dir, "docker.sock")
 }
 
-func setDockerAcceleratorWindows(dockerUrl string) error {
-	configPath := os.Getenv("USERPROFILE") + "\\.docker\\daemon.json"
-	return updateDockerConfig(configPath, dockerUrl)
+// isRootlessDocker 检查当前用户使用的是否为 rootless Docker：非 root 用户且存在 rootless socket，
+// 设置了 DOCKER_HOST 时以其是否指向 rootless socket 为准
+func isRootlessDocker() bool {
+	if runtime.GOOS != "linux" || os.Getuid() == 0 {
+		return false
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host == "unix://"+rootlessDockerSocket()
+	}
+	_, err := os.Stat(rootlessDockerSocket())
+	return err == nil
 }
 
-func setDockerAcceleratorMac(dockerUrl string) error {
-	configPath := os.Getenv("HOME") + "/.docker/daemon.json"
-	return updateDockerConfig(configPath, dockerUrl)
+// 读取 Docker 配置文件，文件不存在或为空时返回空配置
+// 内容无法解析时返回错误，避免覆盖管理员手动编辑但格式有误的配置
+func readDockerConfig(configPath string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf(T("无法打开 Docker 配置文件: %w"), err)
+	}
+	var config map[string]interface{}
+	if len(bytes.TrimSpace(content)) > 0 {
+		if err = json.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf(T("解析 Docker 配置文件[%s]出错，请修正后重试: %w"), configPath, err)
+		}
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	return config, nil
 }
 
-func setDockerAcceleratorLinux(dockerUrl string) error {
-	configPath := "/etc/docker/daemon.json"
-	return updateDockerConfig(configPath, dockerUrl)
+func writeDockerConfig(configPath string, config map[string]interface{}, change string) error {
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	if !DryRun { // rootless Docker 的配置目录可能还不存在
+		if err = os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return err
+		}
+	}
+	if err = applyFile(BackupDocker, configPath, change, append(content, '\n')); err != nil {
+		return fmt.Errorf(T("写入 Docker 配置文件出错: %w"), err)
+	}
+	return nil
 }
 
 func updateDockerConfig(configPath, dockerUrl string) error {
-	file, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE, 0644)
+	config, err := readDockerConfig(configPath)
 	if err != nil {
-		return fmt.Errorf("无法打开 Docker 配置文件: %w", err)
+		return err
 	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
 
-	var config map[string]interface{}
-	if err := json.NewDecoder(file).Decode(&config); err != nil {
-		config = make(map[string]interface{})
+	old := mirrorList(config["registry-mirrors"])
+	mirrors := mergeMirrors(old, dockerUrl, MirrorMode)
+	if slices.Equal(old, mirrors) {
+		Println(T("\n[信息] Docker 配置文件中的加速器已是最新，无需修改。"))
+		return nil
 	}
+	config["registry-mirrors"] = mirrors
 
-	config["registry-mirrors"] = []string{dockerUrl}
+	if err = writeDockerConfig(configPath, config, dockerUrl); err != nil {
+		return err
+	}
+	if DryRun {
+		return nil
+	}
 
-	file, err = os.Create(configPath)
-	if err != nil {
-		return fmt.Errorf("无法重写 Docker 配置文件: %w", err)
+	Println(T("\n[信息] 成功更新 Docker 配置文件，registry-mirrors 变更："))
+	for _, line := range mirrorDiff(old, mirrors) {
+		Println("  " + line)
 	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
+	return nil
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(config); err != nil {
-		return fmt.Errorf("写入 Docker 配置文件出错: %w", err)
+// CheckMirrorMode 检查加速器写入方式
+func CheckMirrorMode() error {
+	switch MirrorMode {
+	case "prepend", "append", "replace":
+		return nil
+	default:
+		return fmt.Errorf(T("未知的加速器写入方式：%s（可选 prepend、append、replace）"), MirrorMode)
 	}
+}
 
-	fmt.Println("\n[信息] 成功更新 Docker 配置文件。")
-	return nil
+// 读取配置中的 registry-mirrors，忽略不是字符串的项
+func mirrorList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	var mirrors []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			mirrors = append(mirrors, s)
+		}
+	}
+	return mirrors
+}
+
+// 比较加速器地址时忽略末尾的 /
+func sameMirror(a, b string) bool {
+	return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
+
+// 按写入方式将加速器地址合并到已有的 registry-mirrors 中，已有地址保持原顺序并去重
+func mergeMirrors(old []string, dockerUrl, mode string) []string {
+	if mode == "replace" {
+		return []string{dockerUrl}
+	}
+	var kept []string
+	for _, m := range old {
+		if sameMirror(m, dockerUrl) || slices.ContainsFunc(kept, func(k string) bool { return sameMirror(k, m) }) {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	if mode == "append" {
+		return append(kept, dockerUrl)
+	}
+	return append([]string{dockerUrl}, kept...)
+}
+
+// 返回 registry-mirrors 修改前后的差异，每行以 +（新增）、-（移除）或空格（保留）开头
+func mirrorDiff(old, mirrors []string) []string {
+	var lines []string
+	for _, m := range old {
+		if !slices.Contains(mirrors, m) {
+			lines = append(lines, "- "+m)
+		}
+	}
+	for _, m := range mirrors {
+		if slices.Contains(old, m) {
+			lines = append(lines, "  "+m)
+		} else {
+			lines = append(lines, "+ "+m)
+		}
+	}
+	return lines
 }
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestMergeMirrors(t *testing.T) {
+	old := []string{"https://a.example.com", "https://new.example.com/", "https://b.example.com", "https://a.example.com/"}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"prepend", []string{"https://new.example.com", "https://a.example.com", "https://b.example.com"}},
+		{"append", []string{"https://a.example.com", "https://b.example.com", "https://new.example.com"}},
+		{"replace", []string{"https://new.example.com"}},
+	}
+	for _, tt := range tests {
+		if got := mergeMirrors(old, "https://new.example.com", tt.mode); !slices.Equal(got, tt.want) {
+			t.Errorf("mergeMirrors(%s) = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+	if got := mergeMirrors(nil, "https://new.example.com", "prepend"); !slices.Equal(got, []string{"https://new.example.com"}) {
+		t.Errorf("mergeMirrors(nil) = %v", got)
+	}
+}
+
+func TestMirrorDiff(t *testing.T) {
+	got := mirrorDiff([]string{"https://a.example.com", "https://old.example.com"}, []string{"https://new.example.com", "https://a.example.com"})
+	want := []string{"- https://old.example.com", "+ https://new.example.com", "  https://a.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("mirrorDiff() = %q, want %q", got, want)
+	}
+}
+
+func TestReadDockerConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for name, path := range map[string]string{
+		"不存在":  filepath.Join(dir, "missing.json"),
+		"空文件":  write("empty.json", " \n"),
+		"null": write("null.json", "null"),
+	} {
+		config, err := readDockerConfig(path)
+		if err != nil || config == nil || len(config) != 0 {
+			t.Errorf("%s: readDockerConfig() = %v, %v, want empty config", name, config, err)
+		}
+	}
+
+	config, err := readDockerConfig(write("daemon.json", `{"debug": true, "registry-mirrors": ["https://a.example.com"]}`))
+	if err != nil || config["debug"] != true || !slices.Equal(mirrorList(config["registry-mirrors"]), []string{"https://a.example.com"}) {
+		t.Errorf("readDockerConfig() = %v, %v", config, err)
+	}
+
+	// 格式有误时不能当作空配置覆盖
+	path := write("broken.json", `{"debug": true,}`)
+	if _, err := readDockerConfig(path); err == nil {
+		t.Error("readDockerConfig(broken) = nil, want error")
+	}
+	if err := updateDockerConfig(path, "https://new.example.com"); err == nil {
+		t.Error("updateDockerConfig(broken) = nil, want error")
+	}
+	if content, _ := os.ReadFile(path); string(content) != `{"debug": true,}` {
+		t.Errorf("broken.json 被修改为 %q", content)
+	}
+}
+
+func TestDockerSetNoUsableIP(t *testing.T) {
+	setupApplyState(t)
+	s := DownloadSpeedSet{{
+		PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.1")}, Sended: 4, Received: 4},
+		Trace:    &TraceData{Failure: "timeout"},
+	}}
+	if err := s.DockerSet(); !errors.Is(err, ErrNoUsableIP) {
+		t.Errorf("DockerSet() = %v, want ErrNoUsableIP", err)
+	}
+	if AppliedIP != "" {
+		t.Errorf("AppliedIP = %q, want empty", AppliedIP)
+	}
+}
+
+func TestRootlessDocker(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("只有 Linux 支持 rootless Docker")
+	}
+	dir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv("DOCKER_HOST", "")
+	if got := rootlessDockerSocket(); got != filepath.Join(dir, "docker.sock") {
+		t.Errorf("rootlessDockerSocket() = %s", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docker.pid"), []byte("1234\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := dockerdPid(true); got != 1234 {
+		t.Errorf("dockerdPid(rootless) = %d, want 1234", got)
+	}
+
+	if isRootlessDocker() { // socket 不存在
+		t.Error("isRootlessDocker() = true, want false")
+	}
+	if err := os.WriteFile(rootlessDockerSocket(), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// root 用户使用系统的 Docker
+	if got, want := isRootlessDocker(), os.Getuid() != 0; got != want {
+		t.Errorf("isRootlessDocker() = %v, want %v", got, want)
+	}
+	// DOCKER_HOST 指向其他地址时以其为准
+	t.Setenv("DOCKER_HOST", "unix:///var/run/docker.sock")
+	if isRootlessDocker() {
+		t.Error("isRootlessDocker(DOCKER_HOST) = true, want false")
+	}
+}
+
+func TestDockerConfigPath(t *testing.T) {
+	old := DockerConfigPath
+	defer func() { DockerConfigPath = old }()
+	DockerConfigPath = "/opt/docker/daemon.json"
+	if got, err := dockerConfigPath(); err != nil || got != DockerConfigPath {
+		t.Errorf("dockerConfigPath() = %s, %v, want -docker-config", got, err)
+	}
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const lockFileName = "dockerst.lock"
+
+var (
+	// 持有运行锁的锁文件
+	lock *os.File
+	// 锁已被其他进程持有
+	errLocked = errors.New("locked")
+)
+
+// 写入系统配置文件：先写入同目录下的临时文件并 fsync，再重命名覆盖原文件，中途出错不会留下不完整的文件
+// 尽可能保留原文件的权限、所有者及 SELinux 上下文，原文件不存在时使用 perm 创建
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	if real, err := filepath.EvalSymlinks(path); err == nil { // 替换链接指向的文件，而不是链接本身
+		path = real
+	}
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if info != nil {
+		perm = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".dockerst-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		_ = os.Remove(tmpPath) // 重命名成功后临时文件已不存在
+	}()
+
+	err = writeTempFile(tmp, content, perm, info)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		copySecurityContext(path, tmpPath)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		// 文件本身是挂载点（如容器中的 /etc/hosts）时无法重命名，只能直接覆盖写入
+		if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+			return writeInPlace(path, content)
+		}
+		return err
+	}
+	return syncDir(dir)
+}
+
+func writeTempFile(tmp *os.File, content []byte, perm os.FileMode, info os.FileInfo) error {
+	if err := tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if info != nil {
+		copyOwner(tmp, info)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		return err
+	}
+	return tmp.Sync()
+}
+
+func writeInPlace(path string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(content); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// AcquireLock 获取运行锁，防止多个 DockerST 同时修改系统配置，已有其他进程持有锁时返回错误
+// 未调用 ReleaseLock 时，锁在进程退出时由系统自动释放
+func AcquireLock() error {
+	dir, err := StateDir()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf(T("无法打开锁文件: %w"), err)
+	}
+	if err = lockFile(file); err != nil {
+		defer file.Close()
+		if !errors.Is(err, errLocked) {
+			return fmt.Errorf(T("无法锁定锁文件: %w"), err)
+		}
+		content := make([]byte, 32)
+		n, _ := file.Read(content)
+		if pid := strings.TrimSpace(string(content[:n])); pid != "" {
+			return fmt.Errorf(T("另一个 DockerST 正在运行（进程 %s）"), pid)
+		}
+		return errors.New(T("另一个 DockerST 正在运行"))
+	}
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	lock = file
+	return nil
+}
+
+// ReleaseLock 释放运行锁，之后其他 DockerST 可以修改系统配置，未持有锁时不做任何操作
+func ReleaseLock() {
+	if lock == nil {
+		return
+	}
+	_ = lock.Truncate(0)
+	_ = lock.Close() // 关闭文件即释放锁
+	lock = nil
+}
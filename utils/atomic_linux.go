@@ -0,0 +1,15 @@
+package utils
+
+import "golang.org/x/sys/unix"
+
+const selinuxXattr = "security.selinux"
+
+// 将原文件的 SELinux 上下文复制到临时文件，未启用 SELinux 或没有权限时忽略
+func copySecurityContext(src, dst string) {
+	buf := make([]byte, 256)
+	n, err := unix.Lgetxattr(src, selinuxXattr, buf)
+	if err != nil || n <= 0 {
+		return
+	}
+	_ = unix.Lsetxattr(dst, selinuxXattr, buf[:n], 0)
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package utils
+
+// 只有 Linux 有 SELinux 上下文
+func copySecurityContext(src, dst string) {}
@@ -0,0 +1,16 @@
+//go:build !unix && !windows
+
+package utils
+
+import "os"
+
+func copyOwner(tmp *os.File, info os.FileInfo) {}
+
+func syncDir(dir string) error {
+	return nil
+}
+
+// 不支持文件锁的系统不检查并发运行
+func lockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "daemon.json")
+	if err := writeFileAtomic(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	// 覆盖已有文件时保留原文件的权限
+	if err := writeFileAtomic(path, []byte(`{"registry-mirrors": []}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0640 {
+		t.Errorf("perm = %v, want 0640", info.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(path); string(content) != `{"registry-mirrors": []}` {
+		t.Errorf("content = %q", content)
+	}
+	// 不留下临时文件
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("目录中的文件 = %v", entries)
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	if runtime.GOOS != "windows" && runtime.GOOS != "linux" && runtime.GOOS != "darwin" && !strings.HasSuffix(runtime.GOOS, "bsd") {
+		t.Skip("当前系统不支持文件锁")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Cleanup(ReleaseLock)
+
+	if err := AcquireLock(); err != nil {
+		t.Fatalf("AcquireLock() = %v", err)
+	}
+	// 同一进程中再次打开锁文件加锁同样会失败，错误中包含持有锁的进程
+	err := AcquireLock()
+	if err == nil || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Fatalf("AcquireLock() = %v, want locked by %d", err, os.Getpid())
+	}
+
+	ReleaseLock()
+	if err := AcquireLock(); err != nil {
+		t.Fatalf("ReleaseLock() 后 AcquireLock() = %v", err)
+	}
+}
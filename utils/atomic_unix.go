@@ -0,0 +1,38 @@
+//go:build unix
+
+package utils
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// 临时文件使用原文件的所有者，没有权限时保持不变
+func copyOwner(tmp *os.File, info os.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = tmp.Chown(int(st.Uid), int(st.Gid))
+	}
+}
+
+// 重命名后同步目录，确保重命名本身已写入磁盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err = d.Sync(); err != nil && err != syscall.EINVAL { // 部分文件系统不支持同步目录
+		return err
+	}
+	return nil
+}
+
+func lockFile(file *os.File) error {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return errLocked
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Windows 下文件的访问权限由所在目录继承，不需要复制所有者
+func copyOwner(tmp *os.File, info os.FileInfo) {}
+
+func syncDir(dir string) error {
+	return nil
+}
+
+func lockFile(file *os.File) error {
+	err := windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &windows.Overlapped{})
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errLocked
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupDirName   = "backups"
+	backupStateName = "backups.json"
+
+	// BackupHosts hosts 文件的备份
+	BackupHosts = "hosts"
+	// BackupDocker Docker 配置文件的备份
+	BackupDocker = "docker"
+)
+
+// BackupRecord 修改文件前保存的一份备份，记录在备份目录的 backups.json 中
+type BackupRecord struct {
+	ID     string    `json:"id"` // 同一次运行中的备份使用相同的 ID
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`             // hosts、docker
+	Path   string    `json:"path"`             // 被修改的文件
+	Backup string    `json:"backup,omitempty"` // 备份文件，修改前文件不存在时为空
+	Change string    `json:"change"`           // 写入的内容：hosts 行、加速器地址，还原时为还原说明
+}
+
+// 本次运行的备份 ID，第一次备份时生成
+var backupID string
+
+// 本次运行修改过的文件，演练模式下为将要修改的文件
+var modifiedFiles = make(map[string]bool)
+
+func backupDir() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, backupDirName)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// LoadBackups 读取全部备份记录（按时间从旧到新），没有备份时返回空
+func LoadBackups() ([]BackupRecord, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(dir, backupStateName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(T("无法读取备份记录: %w"), err)
+	}
+	var records []BackupRecord
+	if err = json.Unmarshal(content, &records); err != nil {
+		return nil, fmt.Errorf(T("解析备份记录出错: %w"), err)
+	}
+	return records, nil
+}
+
+func saveBackups(records []BackupRecord) error {
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = writeFileAtomic(filepath.Join(dir, backupStateName), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf(T("写入备份记录出错: %w"), err)
+	}
+	return nil
+}
+
+// 写入修改后的文件：先备份原内容再原子写入，演练模式下只输出与原内容的差异
+func applyFile(kind, path, change string, content []byte) error {
+	if DryRun {
+		old, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		Print(UnifiedDiff(path, old, content))
+		modifiedFiles[path] = true
+		return nil
+	}
+	if err := backupFile(kind, path, change); err != nil {
+		return fmt.Errorf(T("备份文件失败: %w"), err)
+	}
+	if err := writeFileAtomic(path, content, 0644); err != nil {
+		return err
+	}
+	modifiedFiles[path] = true
+	return nil
+}
+
+// 在修改 path 之前保存其当前内容，同一次运行中每个文件只备份第一次修改前的内容
+func backupFile(kind, path, change string) error {
+	records, err := LoadBackups()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if backupID == "" {
+		backupID = now.Format("20060102-150405")
+		for FindBackup(records, backupID) != nil { // 同一秒内的多次运行
+			now = now.Add(time.Second)
+			backupID = now.Format("20060102-150405")
+		}
+	}
+	for _, r := range records {
+		if r.ID == backupID && r.Path == path {
+			return nil
+		}
+	}
+	record := BackupRecord{ID: backupID, Time: time.Now(), Kind: kind, Path: path, Change: change}
+	content, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		dir, err := backupDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(dir, backupID)
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		record.Backup = filepath.Join(dir, kind+"-"+filepath.Base(path))
+		if err = writeFileAtomic(record.Backup, content, 0600); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	return saveBackups(append(records, record))
+}
+
+// FindBackup 返回指定 ID 的备份记录，latest 为最近一次，不存在时返回空
+func FindBackup(records []BackupRecord, id string) []BackupRecord {
+	if id == "latest" && len(records) > 0 {
+		id = records[len(records)-1].ID
+	}
+	var found []BackupRecord
+	for _, r := range records {
+		if r.ID == id {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// RestoreBackup 将指定备份中的文件还原为备份时的内容（备份时不存在的文件会被删除），返回还原的记录
+// 还原前会再次备份当前内容，因此还原操作本身也可以撤销
+func RestoreBackup(id string) ([]BackupRecord, error) {
+	records, err := LoadBackups()
+	if err != nil {
+		return nil, err
+	}
+	found := FindBackup(records, id)
+	if len(found) == 0 {
+		return nil, fmt.Errorf(T("未找到备份：%s"), id)
+	}
+	for _, r := range found {
+		if err = backupFile(r.Kind, r.Path, fmt.Sprintf(T("还原备份 %s"), r.ID)); err != nil {
+			return nil, err
+		}
+		if r.Backup == "" {
+			if err = os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			modifiedFiles[r.Path] = true
+			continue
+		}
+		content, err := os.ReadFile(r.Backup)
+		if err != nil {
+			return nil, fmt.Errorf(T("无法读取备份文件: %w"), err)
+		}
+		if err = writeFileAtomic(r.Path, content, 0644); err != nil {
+			return nil, fmt.Errorf(T("还原文件[%s]失败：%w"), r.Path, err)
+		}
+		modifiedFiles[r.Path] = true
+	}
+	return found, nil
+}
+
+// PurgeChanges 移除 DockerST 做过的全部修改：hosts 中的 DockerST 部分及各容器运行时配置中 DockerST 设置的加速器地址
+// 返回修改过的文件（只包含 Kind 及 Path）
+func PurgeChanges() ([]BackupRecord, error) {
+	records, err := LoadBackups()
+	if err != nil {
+		return nil, err
+	}
+	// 当前系统的默认文件及备份记录中出现过的文件
+	paths := map[string]map[string]bool{BackupHosts: {}}
+	for _, rt := range Runtimes {
+		paths[rt.Name()] = make(map[string]bool)
+		if path, err := rt.ConfigPath(); err == nil {
+			paths[rt.Name()][path] = true
+		}
+	}
+	mirrors := map[string]bool{strings.TrimRight(DefaultDockerUrl, "/"): true} // 比较时忽略末尾的 /
+	if path, err := hostsPath(); err == nil {
+		paths[BackupHosts][path] = true
+	}
+	for _, r := range records {
+		if paths[r.Kind] == nil {
+			continue
+		}
+		paths[r.Kind][r.Path] = true
+		if r.Kind != BackupHosts && strings.Contains(r.Change, "://") {
+			mirrors[strings.TrimRight(r.Change, "/")] = true
+		}
+	}
+	isOurs := func(mirror string) bool {
+		return mirrors[strings.TrimRight(mirror, "/")]
+	}
+
+	var changed []BackupRecord
+	for _, path := range sortedKeys(paths[BackupHosts]) {
+		content, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(content), "# DockerST Start") {
+			continue
+		}
+		lines, err := readHostsLines(path)
+		if err != nil {
+			return changed, err
+		}
+		if err = rewriteHostsFile(path, lines, nil, T("移除 DockerST 修改")); err != nil {
+			return changed, err
+		}
+		changed = append(changed, BackupRecord{Kind: BackupHosts, Path: path})
+	}
+	for _, rt := range Runtimes {
+		for _, path := range sortedKeys(paths[rt.Name()]) {
+			ok, err := rt.PurgeMirror(path, isOurs)
+			if err != nil {
+				return changed, err
+			}
+			if ok {
+				changed = append(changed, BackupRecord{Kind: rt.Name(), Path: path})
+			}
+		}
+	}
+	return changed, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	setupApplyState(t)
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "daemon.json")
+	created := filepath.Join(dir, "hosts.toml")
+	if err := os.WriteFile(existing, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyFile(BackupDocker, existing, "https://a.example.com", []byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyFile(BackupDocker, existing, "https://b.example.com", []byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyFile(BackupContainerd, created, "https://a.example.com", []byte("created\n")); err != nil {
+		t.Fatal(err)
+	}
+	records, err := LoadBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一次运行中每个文件只备份第一次修改前的内容，修改前不存在的文件没有备份文件
+	if len(records) != 2 || records[0].Path != existing || records[1].Path != created || records[1].Backup != "" {
+		t.Fatalf("备份记录 = %+v", records)
+	}
+	info, err := os.Stat(records[0].Backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(records[0].Backup); string(content) != "{}\n" || info.Mode().Perm() != 0600 {
+		t.Errorf("备份文件 = %q, %v", content, info.Mode().Perm())
+	}
+	if found := FindBackup(records, "latest"); len(found) != 2 || found[0].ID != backupID {
+		t.Errorf("FindBackup(latest) = %+v", found)
+	}
+
+	// 还原是新的一次运行，还原前的内容同样会被备份
+	backupID = ""
+	restored, err := RestoreBackup(records[0].ID)
+	if err != nil || len(restored) != 2 {
+		t.Fatalf("RestoreBackup() = %+v, %v", restored, err)
+	}
+	if content, err := os.ReadFile(existing); err != nil || string(content) != "{}\n" {
+		t.Errorf("%s = %q, %v, want restored", existing, content, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("%s 应被删除: %v", created, err)
+	}
+	if records, err = LoadBackups(); err != nil || len(records) != 4 || records[2].Change != "还原备份 "+records[0].ID {
+		t.Errorf("还原后的备份记录 = %+v, %v", records, err)
+	}
+	if _, err = RestoreBackup("missing"); err == nil {
+		t.Error("RestoreBackup(missing) = nil, want error")
+	}
+}
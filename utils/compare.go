@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ComparedRun 参与对比的一次运行结果（结果文件或历史记录）
+type ComparedRun struct {
+	Name    string
+	Results []ResultRecord
+	Best    string // DockerSet 选择的最优 IP
+}
+
+// RecordDelta 两次运行中都出现的 IP 的结果变化
+type RecordDelta struct {
+	Old, New ResultRecord
+}
+
+// ResultDiff 两次运行结果的差异
+type ResultDiff struct {
+	Old, New ComparedRun
+	Added    []ResultRecord // 新出现的 IP
+	Removed  []ResultRecord // 消失的 IP
+	Changed  []RecordDelta  // 两次都出现的 IP（按新结果的顺序）
+}
+
+// 结果文件中的最优 IP：第一个下载速度大于 0 的 IP（与 DockerSet 一致）
+func bestOf(results []ResultRecord) string {
+	for _, r := range results {
+		if r.DownloadMBps > 0 {
+			return r.IP
+		}
+	}
+	return ""
+}
+
+// LoadComparedRun 读取结果文件，或 history:<ID> 形式指定的历史记录（history:latest 为最近一次，history:prev 为上一次）
+func LoadComparedRun(name string) (ComparedRun, error) {
+	if id, ok := strings.CutPrefix(name, "history:"); ok {
+		runs, err := LoadHistory()
+		if err != nil {
+			return ComparedRun{}, err
+		}
+		var run HistoryRun
+		switch {
+		case id == "latest" && len(runs) > 0:
+			run, ok = runs[len(runs)-1], true
+		case id == "prev" && len(runs) > 1:
+			run, ok = runs[len(runs)-2], true
+		default:
+			run, ok = FindHistoryRun(runs, id)
+		}
+		if !ok {
+			return ComparedRun{}, fmt.Errorf(T("未找到历史记录：%s"), id)
+		}
+		return ComparedRun{Name: "history:" + run.ID, Results: run.Results, Best: run.Best}, nil
+	}
+	f, err := LoadResult(name)
+	if err != nil {
+		return ComparedRun{}, err
+	}
+	return ComparedRun{Name: name, Results: f.Results, Best: bestOf(f.Results)}, nil
+}
+
+// CompareResults 对比两次运行的结果
+func CompareResults(old, new ComparedRun) ResultDiff {
+	d := ResultDiff{Old: old, New: new}
+	oldMap := make(map[string]ResultRecord, len(old.Results))
+	for _, r := range old.Results {
+		oldMap[r.IP] = r
+	}
+	newMap := make(map[string]bool, len(new.Results))
+	for _, r := range new.Results {
+		newMap[r.IP] = true
+		if o, ok := oldMap[r.IP]; ok {
+			d.Changed = append(d.Changed, RecordDelta{Old: o, New: r})
+		} else {
+			d.Added = append(d.Added, r)
+		}
+	}
+	for _, r := range old.Results {
+		if !newMap[r.IP] {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+	return d
+}
+
+// BestChanged 最优 IP 是否变化
+func (d ResultDiff) BestChanged() bool {
+	return d.Old.Best != d.New.Best
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// WriteText 以终端表格形式输出差异
+func (d ResultDiff) WriteText(w io.Writer) {
+	fmt.Fprintf(w, T("旧：%s（%d 个 IP）\n新：%s（%d 个 IP）\n\n"), d.Old.Name, len(d.Old.Results), d.New.Name, len(d.New.Results))
+	if d.BestChanged() {
+		fmt.Fprintf(w, T("[信息] 最优 IP 已变化：%s → %s\n"), orDash(d.Old.Best), orDash(d.New.Best))
+	} else {
+		fmt.Fprintf(w, T("[信息] 最优 IP 未变化：%s\n"), orDash(d.New.Best))
+	}
+	fmt.Fprintf(w, T("\n新出现 %d 个，消失 %d 个，共同 %d 个\n"), len(d.Added), len(d.Removed), len(d.Changed))
+	for _, r := range d.Added {
+		fmt.Fprintf(w, T("  + %-18s延迟 %.0f ms  丢包 %.2f  速度 %.2f MB/s\n"), r.IP, r.DelayMs, r.LossRate, r.DownloadMBps)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(w, T("  - %-18s延迟 %.0f ms  丢包 %.2f  速度 %.2f MB/s\n"), r.IP, r.DelayMs, r.LossRate, r.DownloadMBps)
+	}
+	if len(d.Changed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-18s%-22s%-20s%-26s\n", T("IP 地址"), T("平均延迟 ms (变化)"), T("丢包率 (变化)"), T("下载速度 MB/s (变化)"))
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "%-18s%-22s%-20s%-26s\n", c.New.IP,
+			fmt.Sprintf("%.0f (%+.0f)", c.New.DelayMs, c.New.DelayMs-c.Old.DelayMs),
+			fmt.Sprintf("%.2f (%+.2f)", c.New.LossRate, c.New.LossRate-c.Old.LossRate),
+			fmt.Sprintf("%.2f (%+.2f)", c.New.DownloadMBps, c.New.DownloadMBps-c.Old.DownloadMBps))
+	}
+}
+
+// WriteMarkdown 以 Markdown 形式输出差异
+func (d ResultDiff) WriteMarkdown(w io.Writer) {
+	fmt.Fprint(w, T("## DockerST 测速结果对比\n\n"))
+	fmt.Fprintf(w, T("- 旧：`%s`（%d 个 IP）\n- 新：`%s`（%d 个 IP）\n"), d.Old.Name, len(d.Old.Results), d.New.Name, len(d.New.Results))
+	if d.BestChanged() {
+		fmt.Fprintf(w, T("- **最优 IP 已变化**：`%s` → `%s`\n"), orDash(d.Old.Best), orDash(d.New.Best))
+	} else {
+		fmt.Fprintf(w, T("- 最优 IP 未变化：`%s`\n"), orDash(d.New.Best))
+	}
+	fmt.Fprintf(w, T("- 新出现 %d 个，消失 %d 个，共同 %d 个\n"), len(d.Added), len(d.Removed), len(d.Changed))
+	if len(d.Added)+len(d.Removed) > 0 {
+		fmt.Fprint(w, T("\n| | IP 地址 | 平均延迟 (ms) | 丢包率 | 下载速度 (MB/s) |\n|---|---|---:|---:|---:|\n"))
+		for _, r := range d.Added {
+			fmt.Fprintf(w, "| + | %s | %.0f | %.2f | %.2f |\n", r.IP, r.DelayMs, r.LossRate, r.DownloadMBps)
+		}
+		for _, r := range d.Removed {
+			fmt.Fprintf(w, "| - | %s | %.0f | %.2f | %.2f |\n", r.IP, r.DelayMs, r.LossRate, r.DownloadMBps)
+		}
+	}
+	if len(d.Changed) > 0 {
+		fmt.Fprint(w, T("\n| IP 地址 | 平均延迟 (ms) | 变化 | 丢包率 | 变化 | 下载速度 (MB/s) | 变化 |\n|---|---:|---:|---:|---:|---:|---:|\n"))
+		for _, c := range d.Changed {
+			fmt.Fprintf(w, "| %s | %.0f | %+.0f | %.2f | %+.2f | %.2f | %+.2f |\n", c.New.IP,
+				c.New.DelayMs, c.New.DelayMs-c.Old.DelayMs,
+				c.New.LossRate, c.New.LossRate-c.Old.LossRate,
+				c.New.DownloadMBps, c.New.DownloadMBps-c.Old.DownloadMBps)
+		}
+	}
+}
+
+// WriteDiff 输出差异到文件（path 为空时输出到终端），markdown 为 true 时输出 Markdown
+func (d ResultDiff) WriteDiff(path string, markdown bool) error {
+	w := io.Writer(os.Stdout)
+	if path != "" {
+		fp, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf(T("创建文件[%s]失败：%w"), path, err)
+		}
+		defer fp.Close()
+		w = fp
+	}
+	if markdown {
+		d.WriteMarkdown(w)
+	} else {
+		d.WriteText(w)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBestOf(t *testing.T) {
+	tests := []struct {
+		results []ResultRecord
+		want    string
+	}{
+		{nil, ""},
+		{[]ResultRecord{{IP: "1.1.1.1", DownloadMBps: 5}, {IP: "1.1.1.2", DownloadMBps: 9}}, "1.1.1.1"},
+		// 排在前面的 IP 下载失败时，与 DockerSet 一样选择第一个有速度的 IP
+		{[]ResultRecord{{IP: "1.1.1.1"}, {IP: "1.1.1.2", DownloadMBps: 3}}, "1.1.1.2"},
+		{[]ResultRecord{{IP: "1.1.1.1"}, {IP: "1.1.1.2"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := bestOf(tt.results); got != tt.want {
+			t.Errorf("bestOf(%+v) = %q, want %q", tt.results, got, tt.want)
+		}
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	old := ComparedRun{Name: "old.json", Best: "1.1.1.1", Results: []ResultRecord{
+		{IP: "1.1.1.1", DelayMs: 100, DownloadMBps: 5},
+		{IP: "1.1.1.2", DelayMs: 120, DownloadMBps: 4},
+	}}
+	new := ComparedRun{Name: "new.json", Best: "1.1.1.3", Results: []ResultRecord{
+		{IP: "1.1.1.3", DelayMs: 80, DownloadMBps: 8},
+		{IP: "1.1.1.1", DelayMs: 90, DownloadMBps: 6},
+	}}
+	d := CompareResults(old, new)
+	if len(d.Added) != 1 || d.Added[0].IP != "1.1.1.3" || len(d.Removed) != 1 || d.Removed[0].IP != "1.1.1.2" {
+		t.Errorf("Added = %+v, Removed = %+v", d.Added, d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Old.DelayMs != 100 || d.Changed[0].New.DelayMs != 90 {
+		t.Errorf("Changed = %+v", d.Changed)
+	}
+	if !d.BestChanged() {
+		t.Error("BestChanged() = false")
+	}
+
+	var text, md bytes.Buffer
+	d.WriteText(&text)
+	d.WriteMarkdown(&md)
+	if !strings.Contains(text.String(), "1.1.1.1 → 1.1.1.3") || !strings.Contains(text.String(), "90 (-10)") {
+		t.Errorf("WriteText() =\n%s", text.String())
+	}
+	if !strings.Contains(md.String(), "`1.1.1.1` → `1.1.1.3`") || !strings.Contains(md.String(), "| 1.1.1.1 | 90 | -10 |") {
+		t.Errorf("WriteMarkdown() =\n%s", md.String())
+	}
+}
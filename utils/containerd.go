@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	// BackupContainerd containerd hosts.toml 的备份
+	BackupContainerd = "containerd"
+
+	containerdSocket = "/run/containerd/containerd.sock"
+	dockerHubServer  = "https://registry-1.docker.io"
+)
+
+var (
+	// containerd 主配置文件，测试时替换
+	containerdConfigFile = "/etc/containerd/config.toml"
+	// ContainerdCertsDir containerd 的镜像仓库配置目录（config.toml 中的 config_path）
+	ContainerdCertsDir = "/etc/containerd/certs.d"
+	// ContainerdCapabilities 加速器在 hosts.toml 中的 capabilities
+	ContainerdCapabilities = []string{"pull", "resolve"}
+)
+
+// containerd：certs.d/docker.io/hosts.toml 中的 [host."..."]
+type containerdRuntime struct{}
+
+func (containerdRuntime) Name() string {
+	return BackupContainerd
+}
+
+func (containerdRuntime) Detect() bool {
+	if _, err := os.Stat(containerdSocket); err == nil {
+		return true
+	}
+	_, err := exec.LookPath("containerd")
+	return err == nil
+}
+
+func (containerdRuntime) ConfigPath() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
+	}
+	return filepath.Join(ContainerdCertsDir, "docker.io", "hosts.toml"), nil
+}
+
+func (containerdRuntime) SetMirror(configPath, dockerUrl string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf(T("无法打开 containerd 配置文件: %w"), err)
+	}
+	head, hosts := parseHostsToml(string(content))
+	old := hostURLs(hosts)
+	mirrors := mergeMirrors(old, dockerUrl, MirrorMode)
+	if slices.Equal(old, mirrors) {
+		Println(T("\n[信息] containerd 配置文件中的加速器已是最新，无需修改。"))
+		return ensureContainerdConfigPath()
+	}
+
+	// 按合并后的顺序排列 [host."..."]，加速器使用新的配置
+	var sections []tomlHostSection
+	for _, url := range mirrors {
+		if url == dockerUrl {
+			sections = append(sections, tomlHostSection{url: url, lines: []string{
+				fmt.Sprintf("[host.%q]", url),
+				fmt.Sprintf("  capabilities = [%s]", quoteList(ContainerdCapabilities)),
+			}})
+			continue
+		}
+		for _, h := range hosts {
+			if h.url == url {
+				sections = append(sections, h)
+			}
+		}
+	}
+	if !DryRun {
+		if err = os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return err
+		}
+	}
+	if err = applyFile(BackupContainerd, configPath, dockerUrl, renderHostsToml(head, sections)); err != nil {
+		return fmt.Errorf(T("写入 containerd 配置文件出错: %w"), err)
+	}
+	if !DryRun {
+		Println(T("\n[信息] 成功更新 containerd 配置文件，加速器变更："))
+		for _, line := range mirrorDiff(old, mirrors) {
+			Println("  " + line)
+		}
+	}
+	return ensureContainerdConfigPath()
+}
+
+// 在 config.toml 的 CRI registry 表中设置 config_path，hosts.toml 只有设置后才会生效
+// 已设置 config_path 或使用旧的 registry.mirrors 配置（两者不能同时使用）时不修改，config.toml 不存在时使用 containerd 的默认配置
+func ensureContainerdConfigPath() error {
+	content, err := os.ReadFile(containerdConfigFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf(T("无法打开 containerd 配置文件: %w"), err)
+	}
+	config := string(content)
+	if strings.Contains(config, "config_path") {
+		return nil
+	}
+	if strings.Contains(config, "registry.mirrors") {
+		Printf(T("[提示] %s 中未设置 config_path，需要设置为 %s 后 hosts.toml 才会生效。\n"), containerdConfigFile, ContainerdCertsDir)
+		return nil
+	}
+
+	section := `[plugins."io.containerd.grpc.v1.cri".registry]`
+	for _, line := range strings.Split(config, "\n") {
+		if fields := strings.Fields(strings.ReplaceAll(line, "=", " = ")); len(fields) == 3 && fields[0] == "version" && fields[2] == "3" {
+			section = `[plugins."io.containerd.cri.v1.images".registry]` // containerd 2.x 的配置格式
+		}
+	}
+	setting := fmt.Sprintf("  config_path = %q", ContainerdCertsDir)
+	lines := strings.Split(strings.TrimRight(config, "\n"), "\n")
+	if i := slices.IndexFunc(lines, func(line string) bool { return strings.TrimSpace(line) == section }); i >= 0 {
+		lines = slices.Insert(lines, i+1, setting)
+	} else {
+		lines = append(lines, "", section, setting)
+	}
+	if err = applyFile(BackupContainerd, containerdConfigFile, setting, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+		return fmt.Errorf(T("写入 containerd 配置文件出错: %w"), err)
+	}
+	if !DryRun {
+		Printf(T("[信息] 已在 %s 中设置 config_path = %s，需要重启 containerd 后生效。\n"), containerdConfigFile, ContainerdCertsDir)
+	}
+	return nil
+}
+
+func (containerdRuntime) PurgeMirror(configPath string, isOurs func(mirror string) bool) (bool, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	head, hosts := parseHostsToml(string(content))
+	var kept []tomlHostSection
+	for _, h := range hosts {
+		if !isOurs(h.url) {
+			kept = append(kept, h)
+		}
+	}
+	if len(kept) == len(hosts) {
+		return false, nil
+	}
+	return true, applyFile(BackupContainerd, configPath, T("移除 DockerST 修改"), renderHostsToml(head, kept))
+}
+
+// containerd 每次拉取镜像时都会重新读取 hosts.toml，只有本次运行修改了 config.toml（如添加 config_path）时才需要重启
+func (containerdRuntime) RestartCommand() (*exec.Cmd, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
+	}
+	if !modifiedFiles[containerdConfigFile] {
+		return nil, nil
+	}
+	return exec.Command("systemctl", "restart", "containerd"), nil
+}
+
+// hosts.toml 中的一个 [host."..."] 表，包含其子表（如 [host."...".header]）
+type tomlHostSection struct {
+	url   string
+	lines []string
+}
+
+// 将 hosts.toml 拆分为 [host."..."] 表之前的内容（server 等）及各个 [host."..."] 表，只做简单的按行解析
+func parseHostsToml(content string) (head []string, hosts []tomlHostSection) {
+	inHost := false
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[host.") {
+			url := trimmed
+			if parts := strings.SplitN(trimmed, `"`, 3); len(parts) == 3 {
+				url = parts[1]
+			}
+			if !inHost || hosts[len(hosts)-1].url != url {
+				hosts = append(hosts, tomlHostSection{url: url})
+			}
+			inHost = true
+		} else if strings.HasPrefix(trimmed, "[") { // 其他表放在 [host."..."] 之前
+			inHost = false
+		}
+		if inHost {
+			hosts[len(hosts)-1].lines = append(hosts[len(hosts)-1].lines, line)
+		} else if trimmed != "" || len(head) > 0 {
+			head = append(head, line)
+		}
+	}
+	return
+}
+
+func renderHostsToml(head []string, hosts []tomlHostSection) []byte {
+	hasServer := false
+	for _, line := range head {
+		if strings.HasPrefix(strings.TrimSpace(line), "server") {
+			hasServer = true
+		}
+	}
+	for len(head) > 0 && strings.TrimSpace(head[len(head)-1]) == "" {
+		head = head[:len(head)-1]
+	}
+	var b strings.Builder
+	if !hasServer {
+		fmt.Fprintf(&b, "server = %q\n", dockerHubServer)
+	}
+	for _, line := range head {
+		b.WriteString(line + "\n")
+	}
+	for _, h := range hosts {
+		lines := h.lines
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		b.WriteString("\n")
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(strings.TrimLeft(b.String(), "\n"))
+}
+
+func hostURLs(hosts []tomlHostSection) []string {
+	var urls []string
+	for _, h := range hosts {
+		urls = append(urls, h.url)
+	}
+	return urls
+}
+
+func quoteList(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+const testHostsToml = `server = "https://registry-1.docker.io"
+
+[host."https://a.example.com"]
+  capabilities = ["pull", "resolve"]
+[host."https://a.example.com".header]
+  x-custom = ["1"]
+
+[host."https://b.example.com"]
+  capabilities = ["pull"]
+`
+
+func TestParseHostsToml(t *testing.T) {
+	head, hosts := parseHostsToml(testHostsToml)
+	if want := []string{`server = "https://registry-1.docker.io"`, ""}; !slices.Equal(head, want) {
+		t.Errorf("head = %q, want %q", head, want)
+	}
+	if got, want := hostURLs(hosts), []string{"https://a.example.com", "https://b.example.com"}; !slices.Equal(got, want) {
+		t.Fatalf("hosts = %v, want %v", got, want)
+	}
+	if len(hosts[0].lines) != 5 { // 子表属于同一个 [host."..."]
+		t.Errorf("hosts[0].lines = %q", hosts[0].lines)
+	}
+	if got := string(renderHostsToml(head, hosts)); got != testHostsToml {
+		t.Errorf("renderHostsToml() =\n%s\nwant\n%s", got, testHostsToml)
+	}
+}
+
+func TestRenderHostsTomlAddsServer(t *testing.T) {
+	head, hosts := parseHostsToml(`[host."https://a.example.com"]` + "\n")
+	want := "server = \"https://registry-1.docker.io\"\n\n[host.\"https://a.example.com\"]\n"
+	if got := string(renderHostsToml(head, hosts)); got != want {
+		t.Errorf("renderHostsToml() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureContainerdConfigPath(t *testing.T) {
+	tests := []struct {
+		name, config, want string
+	}{
+		{"v2", "version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\".registry]\n",
+			"version = 2\n\n[plugins.\"io.containerd.grpc.v1.cri\".registry]\n  config_path = \"/etc/containerd/certs.d\"\n"},
+		{"v3 无 registry 表", "version = 3\n",
+			"version = 3\n\n[plugins.\"io.containerd.cri.v1.images\".registry]\n  config_path = \"/etc/containerd/certs.d\"\n"},
+		{"已设置", "version = 2\n[plugins.\"io.containerd.grpc.v1.cri\".registry]\n  config_path = \"/etc/docker/certs.d\"\n", ""},
+		{"registry.mirrors", "version = 2\n[plugins.\"io.containerd.grpc.v1.cri\".registry.mirrors.\"docker.io\"]\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupApplyState(t)
+			oldConfig := containerdConfigFile
+			containerdConfigFile = filepath.Join(t.TempDir(), "config.toml")
+			t.Cleanup(func() { containerdConfigFile = oldConfig })
+			if err := os.WriteFile(containerdConfigFile, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ensureContainerdConfigPath(); err != nil {
+				t.Fatalf("ensureContainerdConfigPath() = %v", err)
+			}
+			content, err := os.ReadFile(containerdConfigFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" { // 不修改
+				want = tt.config
+			}
+			if string(content) != want {
+				t.Errorf("config.toml =\n%s\nwant\n%s", content, want)
+			}
+
+			// 只修改 hosts.toml 时不需要重启，添加 config_path 后才重启
+			cmd, err := containerdRuntime{}.RestartCommand()
+			if runtime.GOOS != "linux" {
+				return
+			}
+			if err != nil || (cmd != nil) != (tt.want != "") {
+				t.Errorf("RestartCommand() = %v, %v", cmd, err)
+			}
+		})
+	}
+}
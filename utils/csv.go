@@ -3,7 +3,6 @@ package utils
 import (
 	"encoding/csv"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"strconv"
@@ -40,12 +39,25 @@ type PingData struct {
 	Sended   int
 	Received int
 	Delay    time.Duration
+	Jitter   time.Duration // 相邻两次延迟之差的平均值
+	Protocol string        // HTTP 测速实际协商的协议，如 HTTP/1.1、HTTP/2.0
+	Colo     string        // 地区三字码（来自 HTTP 测速及下载测速的响应头）
 }
 
 type CloudflareIPData struct {
 	*PingData
 	lossRate      float32
 	DownloadSpeed float64
+	DownloadBytes int64
+	Trace         *TraceData // 下载测速请求各阶段耗时
+
+	// 稳定性测试结果（未进行稳定性测试时为零值）
+	StableSpeed    float64       // 最差值或指定百分位的下载速度
+	StableDelay    time.Duration // 最差值或指定百分位的延迟
+	StableLossRate float32
+	StableSamples  int
+
+	Score float64 // 综合得分（0~100）
 }
 
 // 计算丢包率
@@ -58,30 +70,38 @@ func (cf *CloudflareIPData) getLossRate() float32 {
 }
 
 func (cf *CloudflareIPData) toString() []string {
-	result := make([]string, 6)
+	result := make([]string, 10)
 	result[0] = cf.IP.String()
 	result[1] = strconv.Itoa(cf.Sended)
 	result[2] = strconv.Itoa(cf.Received)
 	result[3] = strconv.FormatFloat(float64(cf.getLossRate()), 'f', 2, 32)
 	result[4] = strconv.FormatFloat(cf.Delay.Seconds()*1000, 'f', 2, 32)
 	result[5] = strconv.FormatFloat(cf.DownloadSpeed/1024/1024, 'f', 2, 32)
+	result[6] = cf.Protocol
+	result[7] = strconv.FormatFloat(cf.Jitter.Seconds()*1000, 'f', 2, 32)
+	result[8] = strconv.FormatFloat(cf.Score, 'f', 2, 32)
+	result[9] = cf.Colo
 	return result
 }
 
-func ExportCsv(data []CloudflareIPData) {
+func ExportCsv(data []CloudflareIPData) error {
 	if noOutput() || len(data) == 0 {
-		return
+		return nil
 	}
 	fp, err := os.Create(Output)
 	if err != nil {
-		log.Fatalf("创建文件[%s]失败：%v", Output, err)
-		return
+		return fmt.Errorf(T("创建文件[%s]失败：%v"), Output, err)
 	}
 	defer fp.Close()
 	w := csv.NewWriter(fp) //创建一个新的写入文件流
-	_ = w.Write([]string{"IP 地址", "已发送", "已接收", "丢包率", "平均延迟", "下载速度 (MB/s)"})
+	// 表头使用与语言无关的字段名，不随 -lang 变化
+	_ = w.Write([]string{"ip", "sent", "received", "loss_rate", "delay_ms", "download_mbps", "protocol", "jitter_ms", "score", "colo"})
 	_ = w.WriteAll(convertToString(data))
 	w.Flush()
+	if err = w.Error(); err != nil {
+		return fmt.Errorf(T("写入文件[%s]失败：%v"), Output, err)
+	}
+	return nil
 }
 
 func convertToString(data []CloudflareIPData) [][]string {
@@ -137,6 +157,17 @@ func (s PingDelaySet) Less(i, j int) bool {
 	if iRate != jRate {
 		return iRate < jRate
 	}
+	if HistoryScores == nil {
+		return s[i].Delay < s[j].Delay
+	}
+	// 使用历史得分时，平均延迟（毫秒）相同的按历史得分排序
+	if iDelay, jDelay := s[i].Delay.Milliseconds(), s[j].Delay.Milliseconds(); iDelay != jDelay {
+		return iDelay < jDelay
+	}
+	iScore, jScore := HistoryScores[s[i].IP.String()], HistoryScores[s[j].IP.String()]
+	if iScore != jScore {
+		return iScore > jScore
+	}
 	return s[i].Delay < s[j].Delay
 }
 func (s PingDelaySet) Swap(i, j int) {
@@ -161,27 +192,30 @@ func (s DownloadSpeedSet) Print() {
 		return
 	}
 	if len(s) <= 0 { // IP数组长度(IP数量) 大于 0 时继续
-		fmt.Println("\n[信息] 完整测速结果 IP 数量为 0，跳过输出结果。")
+		Println(T("\n[信息] 完整测速结果 IP 数量为 0，跳过输出结果。"))
 		return
 	}
 	dateString := convertToString(s) // 转为多维数组 [][]String
 	if len(dateString) < PrintNum {  // 如果IP数组长度(IP数量) 小于  打印次数，则次数改为IP数量
 		PrintNum = len(dateString)
 	}
-	headFormat := "%-16s%-5s%-5s%-5s%-6s%-11s\n"
-	dataFormat := "%-18s%-8s%-8s%-8s%-10s%-15s\n"
+	headFormat := "%-16s%-5s%-5s%-5s%-6s%-11s%-7s%-5s\n"
+	dataFormat := "%-18s%-8s%-8s%-8s%-10s%-15s%-10s%-8s\n"
 	for i := 0; i < PrintNum; i++ { // 如果要输出的 IP 中包含 IPv6，那么就需要调整一下间隔
 		if len(dateString[i][0]) > 15 {
-			headFormat = "%-40s%-5s%-5s%-5s%-6s%-11s\n"
-			dataFormat = "%-42s%-8s%-8s%-8s%-10s%-15s\n"
+			headFormat = "%-40s%-5s%-5s%-5s%-6s%-11s%-7s%-5s\n"
+			dataFormat = "%-42s%-8s%-8s%-8s%-10s%-15s%-10s%-8s\n"
 			break
 		}
 	}
-	fmt.Printf(headFormat, "IP 地址", "已发送", "已接收", "丢包率", "平均延迟", "下载速度 (MB/s)")
+	if Lang != defaultLang { // 中文表头每个字占两列宽，其他语言的表头与数据列宽一致
+		headFormat = dataFormat
+	}
+	Printf(headFormat, T("IP 地址"), T("已发送"), T("已接收"), T("丢包率"), T("平均延迟"), T("下载速度 (MB/s)"), T("协议"), T("得分"))
 	for i := 0; i < PrintNum; i++ {
-		fmt.Printf(dataFormat, dateString[i][0], dateString[i][1], dateString[i][2], dateString[i][3], dateString[i][4], dateString[i][5])
+		Printf(dataFormat, dateString[i][0], dateString[i][1], dateString[i][2], dateString[i][3], dateString[i][4], dateString[i][5], dateString[i][6], dateString[i][8])
 	}
 	if !noOutput() {
-		fmt.Printf("\n完整测速结果已写入 %v 文件，可使用记事本/表格软件查看。\n", Output)
+		Printf(T("\n完整测速结果已写入 %v 文件，可使用记事本/表格软件查看。\n"), Output)
 	}
 }
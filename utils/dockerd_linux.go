@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// 返回正在运行的 dockerd 通过 --config-file 指定的配置文件，未指定时返回空
+// rootless 为 true 时只查找当前用户的 dockerd，否则只查找 root 的 dockerd
+func runningDockerdConfig(rootless bool) string {
+	uid := uint32(0)
+	if rootless {
+		uid = uint32(os.Getuid())
+	}
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range dirs {
+		comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "dockerd" {
+			continue
+		}
+		var st unix.Stat_t
+		if unix.Stat(dir, &st) != nil || st.Uid != uid {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		path := configFileArg(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"))
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) { // 相对路径相对于 dockerd 的工作目录
+			if cwd, err := os.Readlink(filepath.Join(dir, "cwd")); err == nil {
+				path = filepath.Join(cwd, path)
+			}
+		}
+		return path
+	}
+	return ""
+}
+
+// 从命令行参数中取出 --config-file 的值
+func configFileArg(args []string) string {
+	for i, arg := range args {
+		if v, ok := strings.CutPrefix(arg, "--config-file="); ok {
+			return v
+		}
+		if arg == "--config-file" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "testing"
+
+func TestConfigFileArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"/usr/bin/dockerd", "-H", "fd://"}, ""},
+		{[]string{"/usr/bin/dockerd", "--config-file=/opt/docker/daemon.json"}, "/opt/docker/daemon.json"},
+		{[]string{"dockerd", "--config-file", "daemon.json", "--debug"}, "daemon.json"},
+		{[]string{"dockerd", "--config-file"}, ""}, // 缺少参数值
+	}
+	for _, tt := range tests {
+		if got := configFileArg(tt.args); got != tt.want {
+			t.Errorf("configFileArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package utils
+
+// 只有 Linux 可以通过 /proc 读取 dockerd 的命令行
+func runningDockerdConfig(rootless bool) string {
+	return ""
+}
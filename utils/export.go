@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// ResultSchema 结果文件（JSON/NDJSON）的格式标识
+	ResultSchema = "dockerst.result"
+	// ResultSchemaVersion 结果文件格式版本，字段有不兼容变动时递增
+	ResultSchemaVersion = 1
+)
+
+var (
+	// OutputFormat 输出结果文件格式：csv、json、ndjson、md，为空时按文件扩展名判断
+	OutputFormat string
+	// Params 本次运行的测速参数，写入结果文件
+	Params RunParams
+	// DownloadBytes 本次运行下载测速消耗的总流量（字节），写入结果文件及 JSON 摘要
+	DownloadBytes int64
+)
+
+// RunParams 本次运行的测速参数
+type RunParams struct {
+	Version          string   `json:"version"`
+	Mode             string   `json:"mode"` // tcp、http
+	Port             int      `json:"port"`
+	URL              string   `json:"url"`
+	Protocol         string   `json:"protocol"`
+	Routines         int      `json:"routines"`
+	PingTimes        int      `json:"ping_times"`
+	TestCount        int      `json:"test_count"`
+	DownloadTimeSec  float64  `json:"download_time_sec"`
+	MaxDelayMs       int64    `json:"max_delay_ms"`
+	MinDelayMs       int64    `json:"min_delay_ms"`
+	MaxLossRate      float32  `json:"max_loss_rate"`
+	MinSpeedMBps     float64  `json:"min_speed_mbps"`
+	MaxBytes         int64    `json:"max_bytes,omitempty"`
+	MaxTotalBytes    int64    `json:"max_total_bytes,omitempty"`
+	IPFile           string   `json:"ip_file,omitempty"`
+	IPText           string   `json:"ip_text,omitempty"`
+	RetestFile       string   `json:"retest_file,omitempty"`
+	TestAll          bool     `json:"test_all"`
+	StableTop        int      `json:"stable_top,omitempty"`
+	StableRounds     int      `json:"stable_rounds,omitempty"`
+	StablePercentile float64  `json:"stable_percentile,omitempty"`
+	DockerURL        string   `json:"docker_url"`
+	Hosts            []string `json:"hosts,omitempty"` // 加速器域名以外的目标域名
+	SortKey          string   `json:"sort_key"`
+	Weights          string   `json:"weights"`
+}
+
+// ResultFile JSON 结果文件
+type ResultFile struct {
+	Schema        string         `json:"schema"`
+	Version       int            `json:"schema_version"`
+	GeneratedAt   time.Time      `json:"generated_at"`
+	Params        RunParams      `json:"params"`
+	DownloadBytes int64          `json:"download_bytes"` // 下载测速消耗的总流量
+	Results       []ResultRecord `json:"results"`
+}
+
+// ResultRecord 单个 IP 的测速结果，字段名保持稳定，不随界面语言变化
+type ResultRecord struct {
+	IP            string        `json:"ip"`
+	Sent          int           `json:"sent"`
+	Received      int           `json:"received"`
+	LossRate      float64       `json:"loss_rate"`
+	DelayMs       float64       `json:"delay_ms"`
+	JitterMs      float64       `json:"jitter_ms"`
+	DownloadMBps  float64       `json:"download_mbps"`
+	DownloadBytes int64         `json:"download_bytes"`
+	Score         float64       `json:"score"`
+	Protocol      string        `json:"protocol,omitempty"`
+	Colo          string        `json:"colo,omitempty"`
+	Stable        *StableRecord `json:"stable,omitempty"`
+	Trace         *TraceJSON    `json:"trace,omitempty"`
+}
+
+// StableRecord 稳定性测试结果
+type StableRecord struct {
+	Samples      int     `json:"samples"`
+	DownloadMBps float64 `json:"download_mbps"`
+	DelayMs      float64 `json:"delay_ms"`
+	LossRate     float64 `json:"loss_rate"`
+}
+
+// TraceJSON 下载测速请求各阶段耗时
+type TraceJSON struct {
+	ConnectMs  float64 `json:"connect_ms"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	TransferMs float64 `json:"transfer_ms"`
+	Failure    string  `json:"failure,omitempty"`
+}
+
+func round2(f float64) float64 {
+	return float64(int64(f*100+0.5)) / 100
+}
+
+func ms(d time.Duration) float64 {
+	return round2(d.Seconds() * 1000)
+}
+
+func (cf *CloudflareIPData) toRecord() ResultRecord {
+	r := ResultRecord{
+		IP:            cf.IP.String(),
+		Sent:          cf.Sended,
+		Received:      cf.Received,
+		LossRate:      round2(float64(cf.getLossRate())),
+		DelayMs:       ms(cf.Delay),
+		JitterMs:      ms(cf.Jitter),
+		DownloadMBps:  round2(cf.DownloadSpeed / 1024 / 1024),
+		DownloadBytes: cf.DownloadBytes,
+		Score:         round2(cf.Score),
+		Protocol:      cf.Protocol,
+		Colo:          cf.Colo,
+	}
+	if cf.StableSamples > 0 {
+		r.Stable = &StableRecord{
+			Samples:      cf.StableSamples,
+			DownloadMBps: round2(cf.StableSpeed / 1024 / 1024),
+			DelayMs:      ms(cf.StableDelay),
+			LossRate:     round2(float64(cf.StableLossRate)),
+		}
+	}
+	if cf.Trace != nil {
+		r.Trace = &TraceJSON{
+			ConnectMs:  ms(cf.Trace.Connect),
+			TLSMs:      ms(cf.Trace.TLS),
+			TTFBMs:     ms(cf.Trace.TTFB),
+			TransferMs: ms(cf.Trace.Transfer),
+			Failure:    cf.Trace.Failure,
+		}
+	}
+	return r
+}
+
+// NewResultFile 将测速结果转为 JSON 结果文件结构
+func NewResultFile(data []CloudflareIPData) ResultFile {
+	f := ResultFile{
+		Schema:        ResultSchema,
+		Version:       ResultSchemaVersion,
+		GeneratedAt:   time.Now(),
+		Params:        Params,
+		DownloadBytes: DownloadBytes,
+		Results:       make([]ResultRecord, 0, len(data)),
+	}
+	for i := range data {
+		f.Results = append(f.Results, data[i].toRecord())
+	}
+	return f
+}
+
+// 根据参数或文件扩展名判断输出格式
+func outputFormat(path string) string {
+	if OutputFormat != "" {
+		return strings.ToLower(OutputFormat)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".md", ".markdown":
+		return "md"
+	default:
+		return "csv"
+	}
+}
+
+// CheckOutputFormat 检查指定的输出格式是否支持
+func CheckOutputFormat() error {
+	switch strings.ToLower(OutputFormat) {
+	case "", "csv", "json", "ndjson", "md":
+		return nil
+	default:
+		return fmt.Errorf(T("未知的输出格式：%s（可选 csv、json、ndjson、md）"), OutputFormat)
+	}
+}
+
+// Export 按指定格式输出测速结果文件，创建或写入失败时返回错误
+func Export(data []CloudflareIPData) error {
+	if noOutput() || len(data) == 0 {
+		return nil
+	}
+	format := outputFormat(Output)
+	if format == "csv" {
+		return ExportCsv(data)
+	}
+	fp, err := os.Create(Output)
+	if err != nil {
+		return fmt.Errorf(T("创建文件[%s]失败：%v"), Output, err)
+	}
+	defer fp.Close()
+	switch format {
+	case "json":
+		err = writeJSON(fp, data)
+	case "ndjson":
+		err = writeNDJSON(fp, data)
+	case "md":
+		err = writeMarkdown(fp, data)
+	}
+	if err != nil {
+		return fmt.Errorf(T("写入文件[%s]失败：%v"), Output, err)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, data []CloudflareIPData) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewResultFile(data))
+}
+
+// NDJSON 第一行为运行信息（type=run），之后每行一个 IP 的结果（type=result）
+func writeNDJSON(w io.Writer, data []CloudflareIPData) error {
+	f := NewResultFile(data)
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(struct {
+		Type          string    `json:"type"`
+		Schema        string    `json:"schema"`
+		Version       int       `json:"schema_version"`
+		GeneratedAt   time.Time `json:"generated_at"`
+		Params        RunParams `json:"params"`
+		DownloadBytes int64     `json:"download_bytes"`
+	}{"run", f.Schema, f.Version, f.GeneratedAt, f.Params, f.DownloadBytes}); err != nil {
+		return err
+	}
+	for _, r := range f.Results {
+		if err := encoder.Encode(struct {
+			Type string `json:"type"`
+			ResultRecord
+		}{"result", r}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, data []CloudflareIPData) error {
+	f := NewResultFile(data)
+	var b strings.Builder
+	fmt.Fprint(&b, T("# DockerST 测速结果\n\n"))
+	fmt.Fprintf(&b, T("- 时间：%s\n"), f.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, T("- 版本：%s（结果格式 %s v%d）\n"), f.Params.Version, f.Schema, f.Version)
+	fmt.Fprintf(&b, T("- 模式：%s，端口：%d，协议：%s\n"), f.Params.Mode, f.Params.Port, f.Params.Protocol)
+	fmt.Fprintf(&b, T("- 测速地址：%s\n"), f.Params.URL)
+	fmt.Fprintf(&b, T("- 排序：%s（权重：%s）\n"), f.Params.SortKey, f.Params.Weights)
+	fmt.Fprintf(&b, T("- Docker 地址：%s\n\n"), f.Params.DockerURL)
+	fmt.Fprint(&b, T("| IP 地址 | 已发送 | 已接收 | 丢包率 | 平均延迟 | 抖动 | 下载速度 (MB/s) | 协议 | 得分 |\n"))
+	fmt.Fprintf(&b, "|---|---:|---:|---:|---:|---:|---:|---|---:|\n")
+	for _, r := range f.Results {
+		fmt.Fprintf(&b, "| %s | %d | %d | %.2f | %.2f | %.2f | %.2f | %s | %.2f |\n", r.IP, r.Sent, r.Received, r.LossRate, r.DelayMs, r.JitterMs, r.DownloadMBps, r.Protocol, r.Score)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
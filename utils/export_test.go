@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportError(t *testing.T) {
+	oldOutput, oldFormat := Output, OutputFormat
+	defer func() { Output, OutputFormat = oldOutput, oldFormat }()
+	data := []CloudflareIPData{{PingData: &PingData{IP: &net.IPAddr{IP: net.IPv4(1, 1, 1, 1)}, Sended: 4, Received: 4}}}
+
+	dir := t.TempDir()
+	for _, format := range []string{"csv", "json", "ndjson", "md"} {
+		OutputFormat = format
+		Output = filepath.Join(dir, "missing", "result")
+		// 失败时返回错误而不是直接退出，调用方继续应用最优 IP
+		if err := Export(data); err == nil || !strings.Contains(err.Error(), Output) {
+			t.Errorf("Export(%s) = %v, want error", format, err)
+		}
+		Output = filepath.Join(dir, "result."+format)
+		if err := Export(data); err != nil {
+			t.Errorf("Export(%s) = %v", format, err)
+		}
+		if content, _ := os.ReadFile(Output); !strings.Contains(string(content), "1.1.1.1") {
+			t.Errorf("Export(%s) 内容 = %q", format, content)
+		}
+	}
+}
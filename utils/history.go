@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const historyFileName = "history.jsonl"
+
+var (
+	// HistoryFile 历史记录文件，为空时使用 StateDir 下的 history.jsonl
+	HistoryFile string
+	// NoHistory 不记录本次运行的结果
+	NoHistory bool
+)
+
+// HistoryRun 一次运行的历史记录，历史文件每行一条
+type HistoryRun struct {
+	ID      string         `json:"id"`
+	Time    time.Time      `json:"time"`
+	Params  RunParams      `json:"params"`
+	Results []ResultRecord `json:"results"`          // 延迟测速成功的 IP（含下载测速结果）
+	Failed  []string       `json:"failed,omitempty"` // 延迟测速全部丢包的 IP
+	Tested  []string       `json:"tested,omitempty"` // 进行了下载测速的 IP
+	Best    string         `json:"best,omitempty"`   // 最优 IP
+}
+
+// HistoryPoint 某个 IP 在一次运行中的结果
+type HistoryPoint struct {
+	RunID  string
+	Time   time.Time
+	Failed bool          // 延迟测速全部丢包
+	Result *ResultRecord // 延迟测速成功时的结果
+	Tested bool          // 是否进行了下载测速
+}
+
+func historyPath() (string, error) {
+	if HistoryFile != "" {
+		return HistoryFile, nil
+	}
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFileName), nil
+}
+
+// 运行记录 ID：精确到毫秒的时间加随机后缀，避免同时运行（如多个演练）时重复
+func newRunID(now time.Time) string {
+	return fmt.Sprintf("%s-%04x", now.Format("20060102-150405.000"), rand.IntN(0x10000))
+}
+
+// NewHistoryRun 根据延迟测速（全部结果）、下载测速结果及失败 IP 生成本次运行的历史记录
+func NewHistoryRun(pingData PingDelaySet, speedData DownloadSpeedSet, failed []*net.IPAddr) HistoryRun {
+	now := time.Now()
+	run := HistoryRun{
+		ID:     newRunID(now),
+		Time:   now,
+		Params: Params,
+	}
+	// 下载测速结果覆盖延迟测速结果
+	tested := make(map[string]CloudflareIPData)
+	for _, v := range speedData {
+		if v.DownloadBytes > 0 || v.Trace != nil {
+			tested[v.IP.String()] = v
+			run.Tested = append(run.Tested, v.IP.String())
+		}
+	}
+	for _, v := range pingData {
+		if t, ok := tested[v.IP.String()]; ok {
+			v = t
+		}
+		run.Results = append(run.Results, v.toRecord())
+	}
+	for _, ip := range failed {
+		run.Failed = append(run.Failed, ip.String())
+	}
+	if len(speedData) > 0 && speedData[0].DownloadSpeed > 0 {
+		run.Best = speedData[0].IP.String()
+	}
+	return run
+}
+
+// AppendHistory 追加一条运行记录到历史文件
+func AppendHistory(run HistoryRun) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf(T("无法打开历史记录文件: %w"), err)
+	}
+	defer fp.Close()
+	b, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if _, err = fp.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf(T("写入历史记录文件出错: %w"), err)
+	}
+	return nil
+}
+
+// LoadHistory 读取全部历史记录（按时间从旧到新），历史文件不存在时返回空
+func LoadHistory() ([]HistoryRun, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	fp, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(T("无法打开历史记录文件: %w"), err)
+	}
+	defer fp.Close()
+	var runs []HistoryRun
+	reader := bufio.NewReader(fp)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var run HistoryRun
+			if json.Unmarshal(line, &run) == nil { // 跳过损坏的行
+				runs = append(runs, run)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
+
+// PruneHistory 删除早于 before 的记录，并只保留最近 keep 条（keep <= 0 为不限制），返回删除的数量
+func PruneHistory(before time.Time, keep int) (int, error) {
+	runs, err := LoadHistory()
+	if err != nil {
+		return 0, err
+	}
+	var kept []HistoryRun
+	for _, run := range runs {
+		if before.IsZero() || !run.Time.Before(before) {
+			kept = append(kept, run)
+		}
+	}
+	if keep > 0 && len(kept) > keep {
+		kept = kept[len(kept)-keep:]
+	}
+	removed := len(runs) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	path, err := historyPath()
+	if err != nil {
+		return 0, err
+	}
+	var b strings.Builder
+	for _, run := range kept {
+		line, err := json.Marshal(run)
+		if err != nil {
+			return 0, err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err = writeFileAtomic(path, []byte(b.String()), 0644); err != nil {
+		return 0, fmt.Errorf(T("写入历史记录文件出错: %w"), err)
+	}
+	return removed, nil
+}
+
+// FindHistoryRun 按 ID 查找运行记录
+func FindHistoryRun(runs []HistoryRun, id string) (HistoryRun, bool) {
+	for _, run := range runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return HistoryRun{}, false
+}
+
+// IPTrend 返回某个 IP 在各次运行中的结果（按时间从旧到新），未参与测速的运行不包含在内
+func IPTrend(runs []HistoryRun, ip string) []HistoryPoint {
+	var points []HistoryPoint
+	for _, run := range runs {
+		point := HistoryPoint{RunID: run.ID, Time: run.Time}
+		found := false
+		for i := range run.Results {
+			if run.Results[i].IP == ip {
+				point.Result = &run.Results[i]
+				found = true
+				break
+			}
+		}
+		for _, v := range run.Failed {
+			if v == ip {
+				point.Failed = true
+				found = true
+				break
+			}
+		}
+		for _, v := range run.Tested {
+			if v == ip {
+				point.Tested = true
+				break
+			}
+		}
+		if found {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// HistoryScores 各 IP 的历史得分（历次下载测速的平均速度 MB/s），用于延迟测速结果排序时打破平局，为 nil 时不使用
+var HistoryScores map[string]float64
+
+// HistoryScoreMap 按历史记录计算各 IP 的得分：历次下载测速的平均速度（MB/s），延迟测速失败的记为 0
+// 分母为该 IP 进行了下载测速或延迟测速失败的运行次数，从未进行过下载测速的 IP 不包含在内
+func HistoryScoreMap(runs []HistoryRun) map[string]float64 {
+	sum := make(map[string]float64)
+	tested := make(map[string]int)
+	failed := make(map[string]int)
+	for _, run := range runs {
+		inRun := make(map[string]bool, len(run.Tested))
+		for _, ip := range run.Tested {
+			inRun[ip] = true
+		}
+		for _, r := range run.Results {
+			if inRun[r.IP] {
+				sum[r.IP] += r.DownloadMBps
+				tested[r.IP]++
+			}
+		}
+		for _, ip := range run.Failed {
+			failed[ip]++
+		}
+	}
+	scores := make(map[string]float64, len(sum))
+	for ip, s := range sum {
+		scores[ip] = s / float64(tested[ip]+failed[ip])
+	}
+	return scores
+}
+
+// HistoryBest 返回历史得分最高的 k 个 IP（跳过 exclude 中的 IP）
+func HistoryBest(scores map[string]float64, k int, exclude map[string]time.Time) []string {
+	ips := make([]string, 0, len(scores))
+	for ip, score := range scores {
+		if _, ok := exclude[ip]; ok || score <= 0 {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if scores[ips[i]] != scores[ips[j]] {
+			return scores[ips[i]] > scores[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+	if len(ips) > k {
+		ips = ips[:k]
+	}
+	return ips
+}
+
+// HistoryBlacklist 返回在最近参与的 m 次运行中均延迟测速失败的 IP 及其黑名单到期时间（最后一次失败时间 + expiry），已过期的不包含在内
+func HistoryBlacklist(runs []HistoryRun, m int, expiry time.Duration, now time.Time) map[string]time.Time {
+	blacklist := make(map[string]time.Time)
+	if m <= 0 {
+		return blacklist
+	}
+	failures := make(map[string]int) // 从最近一次开始连续失败的次数
+	lastFailed := make(map[string]time.Time)
+	done := make(map[string]bool)         // 已遇到成功记录，不再计数
+	for i := len(runs) - 1; i >= 0; i-- { // 从新到旧
+		for _, r := range runs[i].Results {
+			done[r.IP] = true
+		}
+		for _, ip := range runs[i].Failed {
+			if done[ip] {
+				continue
+			}
+			if failures[ip] == 0 {
+				lastFailed[ip] = runs[i].Time
+			}
+			failures[ip]++
+		}
+	}
+	for ip, n := range failures {
+		if n < m {
+			continue
+		}
+		if until := lastFailed[ip].Add(expiry); until.After(now) {
+			blacklist[ip] = until
+		}
+	}
+	return blacklist
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryBlacklist(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(hour int, ok []string, failed ...string) HistoryRun {
+		r := HistoryRun{Time: base.Add(time.Duration(hour) * time.Hour), Failed: failed}
+		for _, ip := range ok {
+			r.Results = append(r.Results, ResultRecord{IP: ip})
+		}
+		return r
+	}
+	runs := []HistoryRun{
+		run(0, nil, "1.1.1.1", "1.1.1.2", "1.1.1.3"),
+		run(1, []string{"1.1.1.2"}, "1.1.1.1", "1.1.1.3"),
+		run(2, nil, "1.1.1.1", "1.1.1.2", "1.1.1.3"),
+		run(3, nil, "1.1.1.1", "1.1.1.3"),
+	}
+	now := base.Add(4 * time.Hour)
+
+	got := HistoryBlacklist(runs, 3, 24*time.Hour, now)
+	// 1.1.1.2 最近只连续失败 1 次，之前的成功中断了计数
+	if len(got) != 2 || !got["1.1.1.1"].Equal(base.Add(27*time.Hour)) || !got["1.1.1.3"].Equal(base.Add(27*time.Hour)) {
+		t.Errorf("HistoryBlacklist() = %v", got)
+	}
+	if got := HistoryBlacklist(runs, 3, time.Hour, now); len(got) != 0 { // 已过期
+		t.Errorf("HistoryBlacklist(expired) = %v", got)
+	}
+	if got := HistoryBlacklist(runs, 0, 24*time.Hour, now); len(got) != 0 {
+		t.Errorf("HistoryBlacklist(m=0) = %v", got)
+	}
+}
+
+func TestHistoryScoreMap(t *testing.T) {
+	runs := []HistoryRun{
+		{Failed: []string{"1.1.1.1"}}, // 第一次下载测速之前的失败同样计入
+		{Results: []ResultRecord{{IP: "1.1.1.1", DownloadMBps: 9}, {IP: "1.1.1.2", DownloadMBps: 4}}, Tested: []string{"1.1.1.1", "1.1.1.2"}},
+		{Results: []ResultRecord{{IP: "1.1.1.2", DownloadMBps: 8}, {IP: "1.1.1.3", DownloadMBps: 5}}, Tested: []string{"1.1.1.2"}}, // 1.1.1.3 未下载测速
+		{Failed: []string{"1.1.1.1", "1.1.1.4"}},
+	}
+	got := HistoryScoreMap(runs)
+	want := map[string]float64{"1.1.1.1": 3, "1.1.1.2": 6}
+	if len(got) != len(want) {
+		t.Fatalf("HistoryScoreMap() = %v, want %v", got, want)
+	}
+	for ip, score := range want {
+		if got[ip] != score {
+			t.Errorf("HistoryScoreMap()[%s] = %v, want %v", ip, got[ip], score)
+		}
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 678e6, time.UTC)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := newRunID(now) // 同一时刻的多次运行
+		if !strings.HasPrefix(id, "20240102-030405.678-") || len(id) != len("20240102-030405.678-0000") {
+			t.Fatalf("newRunID() = %q", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) < 90 {
+		t.Errorf("同一时刻生成的 ID 重复过多：%d 个不同", len(seen))
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	oldFile := HistoryFile
+	HistoryFile = filepath.Join(t.TempDir(), "history.jsonl")
+	defer func() { HistoryFile = oldFile }()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := AppendHistory(HistoryRun{ID: strconv.Itoa(i), Time: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 先删除第 1 小时之前的记录，再只保留最近 3 条
+	removed, err := PruneHistory(base.Add(time.Hour), 3)
+	if err != nil || removed != 2 {
+		t.Fatalf("PruneHistory() = %d, %v, want 2", removed, err)
+	}
+	runs, err := LoadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	if got := strings.Join(ids, ","); got != "2,3,4" {
+		t.Errorf("剩余记录 = %s, want 2,3,4", got)
+	}
+	if removed, err = PruneHistory(time.Time{}, 0); err != nil || removed != 0 {
+		t.Errorf("PruneHistory(不限制) = %d, %v", removed, err)
+	}
+}
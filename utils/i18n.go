@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const defaultLang = "zh-CN"
+
+// Lang 输出信息使用的语言：zh-CN、en
+var Lang = defaultLang
+
+// T 返回消息在当前语言下的文本，消息以中文原文作为键，目录中未收录时原样返回
+func T(msg string) string {
+	if s, ok := catalogs[Lang][msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// 将 zh_CN.UTF-8、en_US 等语言环境名称转为支持的语言，不支持时返回空
+func normalizeLang(name string) string {
+	name = strings.ToLower(name)
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	switch {
+	case strings.HasPrefix(name, "zh"):
+		return "zh-CN"
+	case strings.HasPrefix(name, "en"):
+		return "en"
+	}
+	return ""
+}
+
+// DetectLang 按 -lang 参数、LC_ALL、LC_MESSAGES、LANG 的顺序确定输出语言，都未指定时使用中文
+// 语言环境为 zh 以外的任何值（包括 C、POSIX）时使用英文
+// 需要在定义命令行参数前调用，参数说明才会使用对应的语言
+func DetectLang(args []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if v, ok := strings.CutPrefix(name, "lang="); ok {
+			if lang := normalizeLang(v); lang != "" {
+				Lang = lang
+				return
+			}
+		} else if name == "lang" && i+1 < len(args) {
+			if lang := normalizeLang(args[i+1]); lang != "" {
+				Lang = lang
+				return
+			}
+		}
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" { // 按 POSIX 规则，优先级高的变量已设置时不再查看后面的变量
+			if Lang = normalizeLang(v); Lang != "zh-CN" {
+				Lang = "en"
+			}
+			return
+		}
+	}
+}
+
+// CheckLang 检查 -lang 参数指定的语言是否支持
+func CheckLang(name string) error {
+	if name != "" && normalizeLang(name) == "" {
+		return fmt.Errorf(T("未知的语言：%s（可选 zh-CN、en）"), name)
+	}
+	return nil
+}
+
+// 各语言的消息目录，中文为源语言不需要目录
+var catalogs = map[string]map[string]string{
+	"en": {
+		"延迟测速线程":                        "Latency test threads",
+		"延迟测速次数":                        "Latency test count per IP",
+		"下载测速数量":                        "Number of IPs to download test",
+		"下载测速时间":                        "Download test duration (seconds)",
+		"指定测速端口":                        "Test port",
+		"指定测速地址":                        "Test URL",
+		"测速协议 (h1/h2/h3)":               "Test protocol (h1/h2/h3)",
+		"切换测速模式":                        "Use HTTP latency test mode",
+		"有效状态代码":                        "Valid HTTP status code",
+		"匹配指定地区":                        "Match colo codes",
+		"平均延迟上限":                        "Max average delay (ms)",
+		"平均延迟下限":                        "Min average delay (ms)",
+		"丢包几率上限":                        "Max loss rate",
+		"下载速度下限":                        "Min download speed (MB/s)",
+		"单个 IP 下载测速流量上限 (MB)":           "Download test byte cap per IP (MB)",
+		"下载测速总流量上限 (MB)":                "Download test byte cap per run (MB)",
+		"稳定性测试数量":                       "Number of top IPs for the stability test",
+		"稳定性测试轮数":                       "Stability test rounds",
+		"稳定性测试间隔":                       "Stability test interval (seconds)",
+		"稳定性排名百分位":                      "Stability ranking percentile",
+		"排序方式 (speed/delay/loss/score)": "Sort key (speed/delay/loss/score)",
+		"综合得分权重":                        "Composite score weights",
+		"显示结果数量":                        "Number of results to print",
+		"IP段数据文件":                       "IP range file",
+		"指定IP段数据":                       "IP ranges",
+		"重新测试结果文件中的 IP":                 "Re-test IPs from a result file",
+		"重新测试结果文件前 N 个 IP":              "Re-test the top N IPs of the result file",
+		"输出结果文件":                        "Result output file",
+		"输出结果格式 (csv/json/ndjson/md)":   "Result output format (csv/json/ndjson/md)",
+		"输出 HTML 报告文件":                  "HTML report output file",
+		"输出各阶段耗时诊断文件":                   "Request phase timing output file",
+		"输出 Prometheus 指标文件":            "Prometheus textfile output",
+		"Prometheus 指标监听地址":             "Prometheus metrics listen address",
+		"历史记录文件":                        "History file",
+		"不记录历史":                         "Do not record history",
+		"重新测试历史最优 IP 数量":                "Number of historically best IPs to re-test",
+		"连续失败多少次后拉黑":                    "Blacklist IPs failing this many runs in a row",
+		"黑名单有效期（小时）":                    "Blacklist expiry (hours)",
+		"关闭在线读取列表":                      "Do not fetch the online IP list",
+		"禁用下载测速":                        "Disable download test",
+		"测速全部 IP":                       "Test every IP",
+		"安静模式（输出 JSON 摘要）":              "Quiet mode (print a JSON summary)",
+		"打印程序版本":                        "Print version",
+		"Docker 地址":                     "Docker mirror URL",
+		"输出语言 (zh-CN/en)":               "Output language (zh-CN/en)",
+		"[小提示] 在使用 [-sl] 参数时，建议搭配 [-tl] 参数，以避免因凑不够 [-dn] 数量而一直测速...": "[Tip] When using [-sl], also set [-tl] to avoid testing forever while not enough IPs reach [-dn]...",
+		"检查版本更新中...": "Checking for updates...",
+		"*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***": "*** New version [%s] available! Please update at [https://github.com/sxhoio/DockerST]! ***",
+		"当前为最新版本 [%s]！\n": "Already the latest version [%s]!\n",
+		"启动指标服务失败：%w":     "failed to start metrics server: %w",
+		"\n*** 发现新版本 [%s]！请前往 [https://github.com/sxhoio/DockerST] 更新！ ***\n": "\n*** New version [%s] available! Please update at [https://github.com/sxhoio/DockerST]! ***\n",
+		"\n[信息] 指标服务运行中（%s/metrics），按 Ctrl+C 退出。\n":                           "\n[Info] Serving metrics at %s/metrics, press Ctrl+C to exit.\n",
+		"按下 回车键 或 Ctrl+C 退出。":                                                 "Press Enter or Ctrl+C to exit.",
+		"[错误] 未知的命令：%s\n":                                                     "[Error] Unknown command: %s\n",
+		"\n[错误] 记录历史失败：":                                                      "\n[Error] Failed to record history:",
+		"用法：":                                                                 "Usage:",
+		"  DockerST history list [-n 数量]        列出最近的运行记录":                    "  DockerST history list [-n count]       List recent runs",
+		"  DockerST history show <IP>            查看 IP 的历史趋势":                 "  DockerST history show <IP>            Show the trend of an IP",
+		"  DockerST history prune [-days 天数] [-keep 数量]  清理历史记录":              "  DockerST history prune [-days days] [-keep count]  Prune history",
+		"[错误] 读取历史记录失败：":                                                      "[Error] Failed to read history:",
+		"显示数量":                                                                "Number of runs to show",
+		"时间":                                                                  "Time",
+		"可用":                                                                  "OK",
+		"失败":                                                                  "Failed",
+		"下载":                                                                  "Speed",
+		"最优 IP":                                                               "Best IP",
+		"[错误] 请指定 IP，例如：DockerST history show 104.16.1.1": "[Error] Please specify an IP, e.g. DockerST history show 104.16.1.1",
+		"[信息] 历史记录中没有 IP %s 的数据。\n":                       "[Info] No history for IP %s.\n",
+		"状态":          "Status",
+		"丢包率":         "Loss",
+		"平均延迟":        "Avg delay",
+		"下载速度 (MB/s)": "Speed (MB/s)",
+		"不可用":         "Unavailable",
+		"删除多少天以前的记录（0 为不按时间删除）":    "Delete runs older than this many days (0 keeps all ages)",
+		"最多保留的记录数量（0 为不限制）":        "Maximum number of runs to keep (0 for no limit)",
+		"[错误] 清理历史记录失败：":           "[Error] Failed to prune history:",
+		"[信息] 已删除 %d 条历史记录。\n":     "[Info] Deleted %d history runs.\n",
+		"[错误] 未知的 history 命令：%s\n": "[Error] Unknown history command: %s\n",
+		"输出 Markdown": "Output Markdown",
+		"输出到文件":       "Write output to file",
+		"用法：DockerST compare [-md] [-o 文件] <旧结果> <新结果>":                                   "Usage: DockerST compare [-md] [-o file] <old result> <new result>",
+		"  结果可以是结果文件（CSV/JSON/NDJSON），或 history:<ID>、history:latest、history:prev 指定的历史记录": "  A result is a result file (CSV/JSON/NDJSON) or a history run given as history:<ID>, history:latest or history:prev",
+		"[错误]": "[Error]",
+		"HTTP/3 (QUIC) 只支持 https:// 测速地址：%s":               "HTTP/3 (QUIC) only supports https:// test URLs: %s",
+		"未知的协议：%s（可选 h1、h2、h3）":                            "unknown protocol: %s (choose h1, h2 or h3)",
+		"\n[信息] 延迟测速结果 IP 数量为 0，跳过下载测速。":                   "\n[Info] No IPs passed the latency test, skipping download test.",
+		"开始下载测速（下限：%.2f MB/s, 数量：%d, 队列：%d, 协议：%s）\n":      "Starting download test (min: %.2f MB/s, count: %d, queue: %d, protocol: %s)\n",
+		"\n[信息] 已达到下载测速总流量上限（%.2f MB），停止下载测速。\n":           "\n[Info] Download byte cap per run reached (%.2f MB), stopping download test.\n",
+		"[信息] 下载测速共消耗流量：%.2f MB\n":                         "[Info] Download test used %.2f MB\n",
+		"[信息] 已加入 %d 个历史最优 IP，跳过 %d 个黑名单 IP（黑名单共 %d 个）。\n": "[Info] Added %d historically best IPs, skipped %d blacklisted IPs (%d blacklisted in total).\n",
+		"意外的错误，情报告：":                                       "Unexpected error, please report:",
+		"解析 IP 段 [%s] 失败：%w":                               "failed to parse IP range [%s]: %w",
+		"读取结果文件失败：%w":                                      "failed to read result file: %w",
+		"[信息] 从结果文件 %s 读取 %d 个 IP 进行重新测试。\n":               "[Info] Re-testing %[2]d IPs from result file %[1]s.\n",
+		"获取在线列表失败，正在使用内置列表":                                "Failed to fetch the online list, using the built-in list",
+		"获取在线列表成功，正在使用在线列表":                                "Fetched the online list, using it",
+		"\n[信息] 已禁用下载测速，跳过稳定性测试。":                          "\n[Info] Download test is disabled, skipping stability test.",
+		"\n开始稳定性测试（数量：%d, 轮数：%d, 间隔：%v, 百分位：P%.0f）\n":      "\nStarting stability test (count: %d, rounds: %d, interval: %v, percentile: P%.0f)\n",
+		"[信息] 等待 %v 后开始第 %d/%d 轮稳定性测试...\n":                "[Info] Waiting %v before stability round %d/%d...\n",
+		"\n[信息] 已达到下载测速总流量上限（%.2f MB），停止稳定性测试。\n":          "\n[Info] Download byte cap per run reached (%.2f MB), stopping stability test.\n",
+		"IP 地址":       "IP address",
+		"样本":          "Samples",
+		"稳定速度 (MB/s)": "Stable (MB/s)",
+		"最差延迟":        "Worst delay",
+		"[信息] 下载测速及稳定性测试共消耗流量：%.2f MB\n": "[Info] Download and stability tests used %.2f MB\n",
+		"可用:": "Available:",
+		"开始延迟测速（模式：HTTP, 协议：%s, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n": "Starting latency test (mode: HTTP, protocol: %s, port: %d, range: %v ~ %v ms, loss: %.2f)\n",
+		"开始延迟测速（模式：TCP, 端口：%d, 范围：%v ~ %v ms, 丢包：%.2f)\n":         "Starting latency test (mode: TCP, port: %d, range: %v ~ %v ms, loss: %.2f)\n",
+		"\n[信息] 未找到最优节点，跳过优选节点。":                                  "\n[Info] No usable IP found, skipping.",
+		"\n[信息] 最优节点：":                                 "\n[Info] Best IP:",
+		" 速度：":                                         " speed:",
+		"\n[信息] 开始写入 hosts 文件...":                      "\n[Info] Writing hosts file...",
+		"\n[错误] 写入 hosts 文件失败：":                        "\n[Error] Failed to write hosts file:",
+		"写入 hosts 文件失败：%w":                             "failed to write hosts file: %w",
+		"\n[错误] 设置 Docker 加速器失败：":                      "\n[Error] Failed to set Docker mirror:",
+		"不支持的操作系统：%s":                                  "unsupported operating system: %s",
+		"无法打开 hosts 文件: %w":                            "cannot open hosts file: %w",
+		"读取 hosts 文件出错: %w":                            "error reading hosts file: %w",
+		"写入 hosts 文件出错: %w":                            "error writing hosts file: %w",
+		"\n[信息] 成功写入 hosts 文件。":                        "\n[Info] Hosts file written.",
+		"设置 Docker 加速器失败: %w":                          "failed to set Docker mirror: %w",
+		"无法打开 Docker 配置文件: %w":                         "cannot open Docker config file: %w",
+		"写入 Docker 配置文件出错: %w":                         "error writing Docker config file: %w",
+		"未找到历史记录：%s":                                   "history run not found: %s",
+		"旧：%s（%d 个 IP）\n新：%s（%d 个 IP）\n\n":             "Old: %s (%d IPs)\nNew: %s (%d IPs)\n\n",
+		"[信息] 最优 IP 已变化：%s → %s\n":                     "[Info] Best IP changed: %s → %s\n",
+		"[信息] 最优 IP 未变化：%s\n":                          "[Info] Best IP unchanged: %s\n",
+		"\n新出现 %d 个，消失 %d 个，共同 %d 个\n":                 "\n%d appeared, %d disappeared, %d in both\n",
+		"  + %-18s延迟 %.0f ms  丢包 %.2f  速度 %.2f MB/s\n": "  + %-18sdelay %.0f ms  loss %.2f  speed %.2f MB/s\n",
+		"  - %-18s延迟 %.0f ms  丢包 %.2f  速度 %.2f MB/s\n": "  - %-18sdelay %.0f ms  loss %.2f  speed %.2f MB/s\n",
+		"平均延迟 ms (变化)":                                 "Delay ms (change)",
+		"丢包率 (变化)":                                     "Loss (change)",
+		"下载速度 MB/s (变化)":                               "Speed MB/s (change)",
+		"## DockerST 测速结果对比\n\n":                       "## DockerST result comparison\n\n",
+		"- 旧：`%s`（%d 个 IP）\n- 新：`%s`（%d 个 IP）\n":       "- Old: `%s` (%d IPs)\n- New: `%s` (%d IPs)\n",
+		"- **最优 IP 已变化**：`%s` → `%s`\n":                "- **Best IP changed**: `%s` → `%s`\n",
+		"- 最优 IP 未变化：`%s`\n":                           "- Best IP unchanged: `%s`\n",
+		"- 新出现 %d 个，消失 %d 个，共同 %d 个\n":                 "- %d appeared, %d disappeared, %d in both\n",
+		"\n| | IP 地址 | 平均延迟 (ms) | 丢包率 | 下载速度 (MB/s) |\n|---|---|---:|---:|---:|\n":                         "\n| | IP address | Avg delay (ms) | Loss | Speed (MB/s) |\n|---|---|---:|---:|---:|\n",
+		"\n| IP 地址 | 平均延迟 (ms) | 变化 | 丢包率 | 变化 | 下载速度 (MB/s) | 变化 |\n|---|---:|---:|---:|---:|---:|---:|\n": "\n| IP address | Avg delay (ms) | Change | Loss | Change | Speed (MB/s) | Change |\n|---|---:|---:|---:|---:|---:|---:|\n",
+		"创建文件[%s]失败：%w":                  "failed to create file [%s]: %w",
+		"创建文件[%s]失败：%v":                  "failed to create file [%s]: %v",
+		"\n[信息] 完整测速结果 IP 数量为 0，跳过输出结果。": "\n[Info] No results, skipping output.",
+		"已发送": "Sent",
+		"已接收": "Recv",
+		"协议":  "Protocol",
+		"得分":  "Score",
+		"\n完整测速结果已写入 %v 文件，可使用记事本/表格软件查看。\n": "\nFull results written to %v, open it with a text editor or spreadsheet.\n",
+		"未知的输出格式：%s（可选 csv、json、ndjson、md）":  "unknown output format: %s (choose csv, json, ndjson or md)",
+		"写入文件[%s]失败：%v":          "failed to write file [%s]: %v",
+		"# DockerST 测速结果\n\n":    "# DockerST results\n\n",
+		"- 时间：%s\n":              "- Time: %s\n",
+		"- 版本：%s（结果格式 %s v%d）\n": "- Version: %s (result schema %s v%d)\n",
+		"- 模式：%s，端口：%d，协议：%s\n":  "- Mode: %s, port: %d, protocol: %s\n",
+		"- 测速地址：%s\n":            "- Test URL: %s\n",
+		"- 排序：%s（权重：%s）\n":       "- Sort: %s (weights: %s)\n",
+		"- Docker 地址：%s\n\n":     "- Docker mirror: %s\n\n",
+		"| IP 地址 | 已发送 | 已接收 | 丢包率 | 平均延迟 | 抖动 | 下载速度 (MB/s) | 协议 | 得分 |\n": "| IP address | Sent | Received | Loss | Avg delay | Jitter | Speed (MB/s) | Protocol | Score |\n",
+		"无法打开历史记录文件: %w":           "cannot open history file: %w",
+		"写入历史记录文件出错: %w":           "error writing history file: %w",
+		"未知的语言：%s（可选 zh-CN、en）":    "unknown language: %s (choose zh-CN or en)",
+		"无法读取结果文件: %w":             "cannot read result file: %w",
+		"不是 DockerST 结果文件":         "not a DockerST result file",
+		"结果文件格式版本 %d 高于当前支持的版本 %d": "result schema version %d is newer than the supported version %d",
+		"解析结果文件出错: %w":             "error parsing result file: %w",
+		"结果文件第 %d 行列数不足":           "not enough columns on line %d of the result file",
+		"\n与之前结果对比：\n":             "\nCompared with the previous results:\n",
+		"丢包率 (旧 → 新)":              "Loss (old → new)",
+		"平均延迟 (旧 → 新)":             "Delay (old → new)",
+		"下载速度 MB/s (旧 → 新)":        "Speed MB/s (old → new)",
+		"%.2f → 不可用":               "%.2f → unavailable",
+		"序号":                       "No.",
+		"下载速度":                     "Speed",
+		"已下载":                      "Downloaded",
+		"结果":                       "Result",
+		"[%d/%d] %s  当前 %.2f MB/s  平均 %.2f MB/s  已下载 %.2f MB  剩余 %.0fs": "[%d/%d] %s  now %.2f MB/s  avg %.2f MB/s  read %.2f MB  left %.0fs",
+		"达标":               "pass",
+		"未达标":              "below min",
+		"\n[错误] 写入指标文件失败：": "\n[Error] Failed to write metrics file:",
+		"未找到可用的 IP":        "no usable IP found",
+		"\n[错误] 创建文件[%s]失败：%v\n":           "\n[Error] Failed to create file [%s]: %v\n",
+		"\n[错误] 写入 HTML 报告失败：%v\n":         "\n[Error] Failed to write HTML report: %v\n",
+		"\nHTML 报告已写入 %v 文件，可使用浏览器打开查看。\n": "\nHTML report written to %v, open it in a browser.\n",
+		"DockerST 测速报告":                    "DockerST report",
+		"写入 hosts 的 IP":                    "IP written to hosts",
+		"未写入":                              "Not written",
+		"测速时间":                             "Tested at",
+		"结果数量":                             "Results",
+		"运行参数":                             "Parameters",
+		"版本":                               "Version",
+		"延迟测速":                             "Latency test",
+		"%s，端口 %d，协议 %s，线程 %d，次数 %d": "%s, port %d, protocol %s, threads %d, count %d",
+		"过滤条件": "Filters",
+		"延迟 %v ~ %v ms，丢包率上限 %v，下载速度下限 %v MB/s": "delay %v ~ %v ms, max loss %v, min speed %v MB/s",
+		"下载测速":             "Download test",
+		"%s，数量 %d，时间 %v 秒": "%s, count %d, duration %v s",
+		"排序":               "Sort",
+		"%s（权重 %s）":        "%s (weights %s)",
+		"测速结果":             "Results",
+		"点击表头排序。":          "Click a column header to sort.",
+		"地区":               "Colo",
+		"平均延迟 (ms)":        "Avg delay (ms)",
+		"抖动 (ms)":          "Jitter (ms)",
+		"延迟分布":             "Delay distribution",
+		"下载速度分布":           "Download speed distribution",
+		"没有下载测速结果。":        "No download test results.",
+		"地区统计":             "Colos",
+		"数量":               "Count",
+		"平均下载速度 (MB/s)":    "Avg speed (MB/s)",
+		"最高下载速度 (MB/s)":    "Best speed (MB/s)",
+		"权重格式错误：%s（应为 名称=数值）":                                          "invalid weight: %s (expected name=value)",
+		"权重数值错误：%s":                                                    "invalid weight value: %s",
+		"未知的权重名称：%s（可选 latency、jitter、loss、speed）":                     "unknown weight name: %s (choose latency, jitter, loss or speed)",
+		"权重不能全部为 0":                                                    "weights cannot all be 0",
+		"未知的排序方式：%s（可选 speed、delay、loss、score）":                        "unknown sort key: %s (choose speed, delay, loss or score)",
+		"可用命令：history、compare、restore":                                 "Available commands: history, compare, restore",
+		"  DockerST restore list                  列出全部备份":              "  DockerST restore list                  List all backups",
+		"  DockerST restore [-restart] <ID|latest>  还原到指定备份之前的内容":      "  DockerST restore [-restart] <ID|latest>  Restore the content saved by a backup",
+		"  DockerST restore [-restart] purge      移除 DockerST 做过的全部修改": "  DockerST restore [-restart] purge      Remove every change made by DockerST",
+		"[信息] 没有备份记录。":                                                 "[Info] No backups.",
+		"类型":                                                           "Kind",
+		"文件":                                                           "File",
+		"修改内容":                                                         "Change",
+		"[信息] 已移除 %s 中 DockerST 的修改。\n":                                "[Info] Removed DockerST changes from %s.\n",
+		"[信息] 没有需要移除的修改。":                                              "[Info] Nothing to remove.",
+		"[信息] 已还原 %s（备份 %s）。\n":                                        "[Info] Restored %s (backup %s).\n",
+		"无法读取备份记录: %w":                                                 "cannot read backup records: %w",
+		"解析备份记录出错: %w":                                                 "error parsing backup records: %w",
+		"写入备份记录出错: %w":                                                 "error writing backup records: %w",
+		"未找到备份：%s":                                                     "backup not found: %s",
+		"还原备份 %s":                                                      "restore backup %s",
+		"无法读取备份文件: %w":                                                 "cannot read backup file: %w",
+		"还原文件[%s]失败：%w":                                                "failed to restore file [%s]: %w",
+		"移除 DockerST 修改":                                               "remove DockerST changes",
+		"无法打开锁文件: %w":                                                  "cannot open lock file: %w",
+		"无法锁定锁文件: %w":                                                  "cannot lock lock file: %w",
+		"另一个 DockerST 正在运行（进程 %s）":                                     "another DockerST is already running (pid %s)",
+		"另一个 DockerST 正在运行":                                            "another DockerST is already running",
+		"\n[信息] Docker 配置文件中的加速器已是最新，无需修改。":                            "\n[Info] The Docker config already has the mirror, nothing to change.",
+		"\n[信息] 成功更新 Docker 配置文件，registry-mirrors 变更：":                 "\n[Info] Docker config file updated, registry-mirrors changes:",
+		"未知的加速器写入方式：%s（可选 prepend、append、replace）":                     "unknown mirror mode: %s (choose prepend, append or replace)",
+		"加速器写入方式 (prepend/append/replace)":                             "How to add the mirror to registry-mirrors (prepend/append/replace)",
+		"备份文件失败: %w":                                                   "failed to back up file: %w",
+		"\n[演练] 以下为将要进行的修改，不会修改任何文件：":                                  "\n[Dry run] The following changes would be made, no file is modified:",
+		"域名":     "Hostname",
+		"无可用 IP": "no usable IP",
+		"其他目标域名，逗号分隔（如 registry-1.docker.io,auth.docker.io）":     "Other target hostnames, comma separated (e.g. registry-1.docker.io,auth.docker.io)",
+		"还原后重启容器运行时服务":                                           "Restart the container runtime service after restoring",
+		"[提示] %s 配置已修改，需要重启 %s 服务才能生效（可使用 -restart 参数）。\n":       "[Tip] The %s config changed, restart the %s service for it to take effect (or use -restart).\n",
+		"\n[演练] 确认重启服务后将执行：":                                     "\n[Dry run] After confirming the restart, would run:",
+		"\n[信息] %s 加速器已设置为：%s\n":                                 "\n[Info] %s mirror set to: %s\n",
+		"\n[提示] 是否重启 %s 服务？(y/n): ":                              "\n[Prompt] Restart the %s service? (y/n): ",
+		"无法打开 containerd 配置文件: %w":                               "cannot open containerd config file: %w",
+		"\n[信息] containerd 配置文件中的加速器已是最新，无需修改。":                  "\n[Info] The containerd config already has the mirror, nothing to change.",
+		"写入 containerd 配置文件出错: %w":                               "error writing containerd config file: %w",
+		"\n[信息] 成功更新 containerd 配置文件，加速器变更：":                     "\n[Info] containerd config file updated, mirror changes:",
+		"[提示] %s 中未设置 config_path，需要设置为 %s 后 hosts.toml 才会生效。\n": "[Tip] config_path is not set in %s, set it to %s for hosts.toml to take effect.\n",
+		"未知的容器运行时：%s（可选 auto/%s）":                                "unknown container runtime: %s (choose auto/%s)",
+		"未检测到支持的容器运行时（可使用 -runtime 参数指定）":                        "no supported container runtime detected (use -runtime to choose one)",
+		"重启 %s 服务失败: %w":                                         "failed to restart %s service: %w",
+		"容器运行时 (auto/docker/containerd/podman)":                  "Container runtime (auto/docker/containerd/podman)",
+		"无法打开 registries.conf 配置文件: %w":                          "cannot open registries.conf: %w",
+		"\n[信息] registries.conf 配置文件中的加速器已是最新，无需修改。":             "\n[Info] registries.conf already has the mirror, nothing to change.",
+		"写入 registries.conf 配置文件出错: %w":                          "error writing registries.conf: %w",
+		"\n[信息] 成功更新 registries.conf 配置文件，加速器变更：":                "\n[Info] registries.conf updated, mirror changes:",
+		"Docker 配置文件路径（默认自动检测）":                                  "Docker daemon.json path (detected by default)",
+		"设置加速器后重启服务 (never/always/ask)":                          "Restart the service after setting the mirror (never/always/ask)",
+		"\n[提示] 需要重启 %s 服务才能生效（可使用 -restart always 参数自动重启）：%s\n": "\n[Tip] Restart the %s service for the change to take effect (or use -restart always): %s\n",
+		"未知的重启策略：%s（可选 never、always、ask）":                        "unknown restart policy: %s (choose never, always or ask)",
+		"[信息] 已执行：%s\n":                                          "[Info] Ran: %s\n",
+		"[信息] 等待 %s 服务恢复...\n":                                   "[Info] Waiting for the %s service to come back...\n",
+		"[信息] %s 服务运行正常（版本 %s）。\n":                               "[Info] The %s service is healthy (version %s).\n",
+		"%s 服务在 %v 内未恢复: %w":                                     "%s service did not come back within %v: %w",
+		"写入后不验证修改是否生效":                                           "Do not verify the changes after writing",
+		"验证超时时间（秒），超时未通过时自动还原":                                   "Verification timeout (seconds), changes are rolled back if it fails",
+		"验证时通过加速器获取清单的镜像":                                        "Image whose manifest is fetched through the mirror to verify",
+		"\n[错误] 验证失败：":                                           "\n[Error] Verification failed:",
+		"\n[信息] 正在还原本次修改...":                                     "\n[Info] Rolling back this run's changes...",
+		"\n[错误] 还原失败：":                                           "\n[Error] Rollback failed:",
+		"还原本次修改失败: %w":                                           "failed to roll back this run's changes: %w",
+		"验证未通过，已还原本次修改":                                          "verification failed, changes rolled back",
+		"\n[信息] 开始验证修改是否生效...":                                   "\n[Info] Verifying the changes...",
+		"Docker 已加载加速器":                                          "Docker loaded the mirror",
+		"%s 解析为 %s":                                              "%s resolves to %s",
+		"通过加速器获取 %s 的清单":                                         "Fetch the %s manifest through the mirror",
+		"[信息] 已还原 %s。\n":                                         "[Info] Restored %s.\n",
+		"Docker 的 registry-mirrors 中没有 %s：%v":                    "%s is not in Docker's registry-mirrors: %v",
+		"解析结果为 %v":                                               "resolves to %v",
+		"%s: 认证失败":                                               "%s: authentication failed",
+		"不支持的认证方式：%s":                                            "unsupported authentication challenge: %s",
+		"未获取到 token":                                             "no token in the response",
+		"解析 Docker 配置文件[%s]出错，请修正后重试: %w":                        "Failed to parse Docker config file [%s], please fix it and retry: %w",
+		"每个目标域名除 DNS 解析结果外额外测试的测速结果 IP 数量":                       "Number of top speed-test IPs to try per target hostname, in addition to its DNS answers",
+		"\n开始测试目标域名（数量：%d, 候选 IP：DNS 解析结果及测速结果前 %d 个）\n":         "\nTesting target hostnames (count: %d, candidate IPs: DNS answers and top %d speed-test results)\n",
+		"可用/候选":                "Usable/Tried",
+		"[信息] 解析 %s 失败：%v\n":   "[Info] Failed to resolve %s: %v\n",
+		"\n[警告] 输出结果文件失败：%v\n": "\n[Warning] Failed to write the result file: %v\n",
+		"演练模式，只显示将要进行的修改，不写入任何文件（结果文件、历史记录、指标文件等）":                   "Dry run: only show the changes that would be made, without writing any files (results, history, metrics, etc.)",
+		"registries.conf 为 v1 格式，不支持配置加速器，请先转换为 v2 格式（[[registry]]）": "registries.conf is in the v1 format, which does not support mirrors; please convert it to the v2 format ([[registry]]) first",
+		"\n[警告] 输出各阶段耗时诊断结果失败：%v\n":                                  "\n[Warning] Failed to write the phase timing trace: %v\n",
+		"[信息] 已在 %s 中设置 config_path = %s，需要重启 containerd 后生效。\n":     "[Info] Set config_path in %s to %s; restart containerd for it to take effect.\n",
+		"P%.0f 延迟": "P%.0f delay",
+	},
+}
@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestDetectLang(t *testing.T) {
+	oldLang := Lang
+	defer func() { Lang = oldLang }()
+
+	tests := []struct {
+		args                  []string
+		lcAll, lcMessages, lc string
+		want                  string
+	}{
+		{nil, "", "", "", "zh-CN"},
+		{nil, "", "", "zh_CN.UTF-8", "zh-CN"},
+		{nil, "", "", "en_US.UTF-8", "en"},
+		{nil, "", "", "C", "en"},
+		{nil, "", "", "POSIX", "en"},
+		{nil, "", "", "C.UTF-8", "en"},
+		{nil, "", "", "fr_FR.UTF-8", "en"},
+		{nil, "", "zh_TW.UTF-8", "C", "zh-CN"}, // 优先级高的变量已设置时不再查看后面的变量
+		{nil, "C", "zh_CN.UTF-8", "", "en"},
+		{[]string{"-lang", "zh-CN"}, "C", "", "", "zh-CN"},
+		{[]string{"--lang=en"}, "", "", "zh_CN.UTF-8", "en"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_MESSAGES", tt.lcMessages)
+		t.Setenv("LANG", tt.lc)
+		Lang = defaultLang
+		DetectLang(tt.args)
+		if Lang != tt.want {
+			t.Errorf("DetectLang(%v, LC_ALL=%q, LC_MESSAGES=%q, LANG=%q) = %s, want %s", tt.args, tt.lcAll, tt.lcMessages, tt.lc, Lang, tt.want)
+		}
+	}
+}
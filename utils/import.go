@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadResult 读取之前输出的结果文件（CSV、JSON 或 NDJSON），返回其中的测速结果（保持文件中的顺序）
+// CSV 结果文件没有记录运行参数，返回的 Params 为空
+func LoadResult(path string) (ResultFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ResultFile{}, fmt.Errorf(T("无法读取结果文件: %w"), err)
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if isNDJSON(trimmed) {
+			return parseNDJSONResult(trimmed)
+		}
+		return parseJSONResult(trimmed)
+	}
+	return parseCSVResult(content)
+}
+
+// NDJSON 结果文件的第一行是带 type 字段的完整 JSON 对象，JSON 结果文件为单个对象（第一行不完整或没有 type 字段）
+func isNDJSON(content []byte) bool {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	var head struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(line, &head) == nil && head.Type != ""
+}
+
+func parseJSONResult(content []byte) (ResultFile, error) {
+	var f ResultFile
+	if err := json.Unmarshal(content, &f); err != nil {
+		return f, fmt.Errorf(T("解析结果文件出错: %w"), err)
+	}
+	return f, checkResultSchema(f)
+}
+
+// 检查结果文件的格式标识及版本
+func checkResultSchema(f ResultFile) error {
+	if f.Schema != ResultSchema {
+		return errors.New(T("不是 DockerST 结果文件"))
+	}
+	if f.Version > ResultSchemaVersion {
+		return fmt.Errorf(T("结果文件格式版本 %d 高于当前支持的版本 %d"), f.Version, ResultSchemaVersion)
+	}
+	return nil
+}
+
+func parseNDJSONResult(content []byte) (ResultFile, error) {
+	var f ResultFile
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(line, &head); err != nil {
+			return f, fmt.Errorf(T("解析结果文件出错: %w"), err)
+		}
+		switch head.Type {
+		case "run":
+			if err := json.Unmarshal(line, &f); err != nil {
+				return f, fmt.Errorf(T("解析结果文件出错: %w"), err)
+			}
+		case "result":
+			var r ResultRecord
+			if err := json.Unmarshal(line, &r); err != nil {
+				return f, fmt.Errorf(T("解析结果文件出错: %w"), err)
+			}
+			f.Results = append(f.Results, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return f, fmt.Errorf(T("解析结果文件出错: %w"), err)
+	}
+	return f, checkResultSchema(f)
+}
+
+// 按 ExportCsv 的列顺序解析：IP、已发送、已接收、丢包率、平均延迟、下载速度、协议、抖动、得分、地区（旧版本文件只有前 6 列）
+func parseCSVResult(content []byte) (ResultFile, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return ResultFile{}, fmt.Errorf(T("解析结果文件出错: %w"), err)
+	}
+	f := ResultFile{Schema: ResultSchema, Version: ResultSchemaVersion}
+	for i, row := range rows {
+		if i == 0 { // 表头
+			continue
+		}
+		if len(row) < 6 {
+			return f, fmt.Errorf(T("结果文件第 %d 行列数不足"), i+1)
+		}
+		num := func(col int) float64 {
+			if col >= len(row) {
+				return 0
+			}
+			v, _ := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+			return v
+		}
+		r := ResultRecord{
+			IP:           strings.TrimSpace(row[0]),
+			Sent:         int(num(1)),
+			Received:     int(num(2)),
+			LossRate:     num(3),
+			DelayMs:      num(4),
+			DownloadMBps: num(5),
+			JitterMs:     num(7),
+			Score:        num(8),
+		}
+		if len(row) > 6 {
+			r.Protocol = row[6]
+		}
+		if len(row) > 9 {
+			r.Colo = row[9]
+		}
+		f.Results = append(f.Results, r)
+	}
+	return f, nil
+}
+
+// PrintRetest 并排输出重新测试的结果与之前结果的对比
+func PrintRetest(old []ResultRecord, data DownloadSpeedSet) {
+	if NoPrintResult() || len(old) == 0 {
+		return
+	}
+	current := make(map[string]*CloudflareIPData, len(data))
+	for i := range data {
+		current[data[i].IP.String()] = &data[i]
+	}
+	Print(T("\n与之前结果对比：\n"))
+	Printf("%-18s%-20s%-20s%-26s\n", T("IP 地址"), T("丢包率 (旧 → 新)"), T("平均延迟 (旧 → 新)"), T("下载速度 MB/s (旧 → 新)"))
+	for _, r := range old {
+		cf, ok := current[r.IP]
+		if !ok {
+			Printf("%-18s%-20s%-20s%-26s\n", r.IP, fmt.Sprintf("%.2f → -", r.LossRate),
+				fmt.Sprintf("%.0f → -", r.DelayMs), fmt.Sprintf(T("%.2f → 不可用"), r.DownloadMBps))
+			continue
+		}
+		newSpeed := cf.DownloadSpeed / 1024 / 1024
+		Printf("%-18s%-20s%-20s%-26s\n", r.IP,
+			fmt.Sprintf("%.2f → %.2f", r.LossRate, cf.getLossRate()),
+			fmt.Sprintf("%.0f → %.0f", r.DelayMs, cf.Delay.Seconds()*1000),
+			fmt.Sprintf("%.2f → %.2f (%+.2f)", r.DownloadMBps, newSpeed, newSpeed-r.DownloadMBps))
+	}
+}
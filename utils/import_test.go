@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVResult(t *testing.T) {
+	content := "IP 地址,已发送,已接收,丢包率,平均延迟,下载速度 (MB/s),协议,抖动,得分,地区\n" +
+		"1.1.1.1,4,4,0.00,100.50,12.34,HTTP/2.0,3.20,88.10,HKG\n" +
+		"1.1.1.2,4,3,0.25,120,0.00\n" // 旧版本文件只有前 6 列
+	f, err := parseCSVResult([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Results) != 2 || f.Schema != ResultSchema {
+		t.Fatalf("parseCSVResult() = %+v", f)
+	}
+	want := ResultRecord{IP: "1.1.1.1", Sent: 4, Received: 4, DelayMs: 100.5, DownloadMBps: 12.34, Protocol: "HTTP/2.0", JitterMs: 3.2, Score: 88.1, Colo: "HKG"}
+	if got := f.Results[0]; got.IP != want.IP || got.Sent != want.Sent || got.Received != want.Received ||
+		got.DelayMs != want.DelayMs || got.DownloadMBps != want.DownloadMBps || got.Protocol != want.Protocol ||
+		got.JitterMs != want.JitterMs || got.Score != want.Score || got.Colo != want.Colo {
+		t.Errorf("Results[0] = %+v, want %+v", got, want)
+	}
+	if got := f.Results[1]; got.LossRate != 0.25 || got.Protocol != "" || got.Score != 0 {
+		t.Errorf("Results[1] = %+v", got)
+	}
+
+	if _, err := parseCSVResult([]byte("IP\n1.1.1.1,4\n")); err == nil {
+		t.Error("列数不足时应返回错误")
+	}
+}
+
+func TestLoadResult(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, content string) (ResultFile, error) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadResult(path)
+	}
+
+	f, err := load("result.json", "{\n  \"schema\": \"dockerst.result\",\n  \"schema_version\": 1,\n  \"results\": [{\"ip\": \"1.1.1.1\"}]\n}\n")
+	if err != nil || len(f.Results) != 1 || f.Results[0].IP != "1.1.1.1" {
+		t.Errorf("LoadResult(json) = %+v, %v", f, err)
+	}
+	f, err = load("result.ndjson", `{"type":"run","schema":"dockerst.result","schema_version":1}`+"\n"+`{"type":"result","ip":"1.1.1.2"}`+"\n")
+	if err != nil || len(f.Results) != 1 || f.Results[0].IP != "1.1.1.2" {
+		t.Errorf("LoadResult(ndjson) = %+v, %v", f, err)
+	}
+
+	tests := []struct {
+		name, content, want string
+	}{
+		// 单个 JSON 对象返回 JSON 的错误，而不是按 NDJSON 解析的错误
+		{"new.json", `{"schema":"dockerst.result","schema_version":99,"results":[]}`, "99"},
+		{"broken.json", "{\n  \"schema\": \"dockerst.result\",\n  \"results\": [\n", "解析结果文件出错"},
+		{"new.ndjson", `{"type":"run","schema":"dockerst.result","schema_version":99}`, "99"},
+		{"other.ndjson", `{"type":"run","schema":"other"}`, "不是 DockerST 结果文件"},
+	}
+	for _, tt := range tests {
+		if _, err := load(tt.name, tt.content); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadResult(%s) = %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// LiveView 下载测速实时进度，显示当前 IP 的速度、已下载及剩余时间，并逐行输出已完成的结果
+// 非终端输出（如重定向到文件、管道）时不启用
+type LiveView struct {
+	enabled bool
+	m       sync.Mutex
+	lastLen int
+	lastOut time.Time
+	done    int
+	total   int
+}
+
+// IsTerminal 标准输出是否为终端
+func IsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+func NewLiveView(total int) *LiveView {
+	return &LiveView{enabled: !Quiet && IsTerminal(), total: total}
+}
+
+func (v *LiveView) Enabled() bool {
+	return v != nil && v.enabled
+}
+
+// Header 输出已完成结果表格的表头
+func (v *LiveView) Header() {
+	if !v.Enabled() {
+		return
+	}
+	fmt.Printf("%-6s%-18s%-10s%-14s%-14s%-8s\n", T("序号"), T("IP 地址"), T("平均延迟"), T("下载速度"), T("已下载"), T("结果"))
+}
+
+// Update 刷新当前 IP 的实时进度（最多每 200ms 刷新一次）
+func (v *LiveView) Update(ip string, current, average float64, read int64, remain time.Duration) {
+	if !v.Enabled() {
+		return
+	}
+	v.m.Lock()
+	defer v.m.Unlock()
+	if time.Since(v.lastOut) < 200*time.Millisecond {
+		return
+	}
+	v.lastOut = time.Now()
+	if remain < 0 {
+		remain = 0
+	}
+	line := fmt.Sprintf(T("[%d/%d] %s  当前 %.2f MB/s  平均 %.2f MB/s  已下载 %.2f MB  剩余 %.0fs"),
+		v.done+1, v.total, ip, current/1024/1024, average/1024/1024, float64(read)/1024/1024, remain.Seconds())
+	v.print(line)
+}
+
+// Done 清除实时进度行并输出当前 IP 的最终结果
+func (v *LiveView) Done(data CloudflareIPData, passed bool) {
+	if !v.Enabled() {
+		return
+	}
+	v.m.Lock()
+	defer v.m.Unlock()
+	v.done++
+	v.print("")
+	result := T("达标")
+	if !passed {
+		result = T("未达标")
+	}
+	if data.Trace != nil && data.Trace.Failure != "" {
+		result = data.Trace.Failure
+	}
+	fmt.Printf("\r%-6d%-18s%-10s%-14s%-14s%-8s\n", v.done, data.IP.String(),
+		fmt.Sprintf("%.0fms", data.Delay.Seconds()*1000),
+		fmt.Sprintf("%.2f MB/s", data.DownloadSpeed/1024/1024),
+		fmt.Sprintf("%.2f MB", float64(data.DownloadBytes)/1024/1024),
+		result)
+	v.lastOut = time.Time{}
+}
+
+// 覆盖输出当前行
+func (v *LiveView) print(line string) {
+	pad := ""
+	if n := v.lastLen - len([]rune(line)); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+	fmt.Print("\r" + line + pad)
+	if line == "" {
+		fmt.Print("\r")
+	}
+	v.lastLen = len([]rune(line))
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 捕获 f 输出到标准输出的内容
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	f()
+	_ = w.Close()
+	return <-out
+}
+
+func TestLiveView(t *testing.T) {
+	data := CloudflareIPData{
+		PingData:      &PingData{IP: &net.IPAddr{IP: net.IPv4(1, 1, 1, 1)}, Delay: 120 * time.Millisecond},
+		DownloadSpeed: 5 * 1024 * 1024,
+		DownloadBytes: 10 * 1024 * 1024,
+	}
+	v := &LiveView{enabled: true, total: 2}
+	out := captureStdout(t, func() {
+		v.Update("1.1.1.1", 6*1024*1024, 5*1024*1024, 3*1024*1024, 2*time.Second)
+		v.Update("1.1.1.1", 7*1024*1024, 5*1024*1024, 4*1024*1024, time.Second) // 200ms 内不重复刷新
+		v.Done(data, true)
+		v.Done(data, false)
+	})
+	if want := "[1/2] 1.1.1.1  当前 6.00 MB/s  平均 5.00 MB/s  已下载 3.00 MB  剩余 2s"; !strings.Contains(out, want) {
+		t.Errorf("进度输出缺少 %q:\n%q", want, out)
+	}
+	if strings.Contains(out, "当前 7.00") {
+		t.Errorf("200ms 内重复刷新了进度:\n%q", out)
+	}
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "120ms") || !strings.Contains(lines[0], "5.00 MB/s") ||
+		!strings.HasSuffix(strings.TrimSpace(lines[0]), "达标") || !strings.HasSuffix(strings.TrimSpace(lines[1]), "未达标") {
+		t.Errorf("结果行 = %q", lines)
+	}
+
+	// 非终端或安静模式下不输出
+	if out := captureStdout(t, func() {
+		disabled := NewLiveView(2)
+		disabled.Header()
+		disabled.Update("1.1.1.1", 1, 1, 1, time.Second)
+		disabled.Done(data, true)
+	}); out != "" {
+		t.Errorf("未启用时输出了 %q", out)
+	}
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// MetricsFile node_exporter textfile collector 指标文件（*.prom），为空则不输出
+	MetricsFile string
+	// MetricsAddr /metrics 监听地址（如 :9101），为空则不启动
+	MetricsAddr string
+
+	metricsMu   sync.RWMutex
+	metricsData DownloadSpeedSet
+	metricsRun  RunSummary
+	// 更新指标时写入 hosts 的 IP 及其测速结果的副本，/metrics 请求与主流程并发，不直接读取 AppliedIP
+	metricsAppliedIP   string
+	metricsAppliedData *CloudflareIPData
+)
+
+// RunSummary 本次运行的统计信息
+type RunSummary struct {
+	Start     time.Time
+	Duration  time.Duration
+	Tested    int // 参与延迟测速的 IP 数量
+	Available int // 延迟测速成功的 IP 数量
+	Filtered  int // 通过延迟、丢包条件过滤的 IP 数量
+	Download  int // 下载测速结果数量
+}
+
+// UpdateMetrics 更新 /metrics 及指标文件中的数据
+func UpdateMetrics(data DownloadSpeedSet, summary RunSummary) {
+	metricsMu.Lock()
+	metricsData = append(DownloadSpeedSet(nil), data...)
+	metricsRun = summary
+	metricsAppliedIP, metricsAppliedData = AppliedIP, nil
+	if appliedData != nil {
+		applied := *appliedData
+		metricsAppliedData = &applied
+	}
+	metricsMu.Unlock()
+	if MetricsFile != "" {
+		if err := writeMetricsFile(MetricsFile); err != nil {
+			Println(T("\n[错误] 写入指标文件失败："), err)
+		}
+	}
+}
+
+// 先写入临时文件再重命名，避免 node_exporter 读到写了一半的文件
+func writeMetricsFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".dockerst-*.prom.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	WriteMetrics(tmp)
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	_ = os.Chmod(tmp.Name(), 0644)
+	return os.Rename(tmp.Name(), path)
+}
+
+// ServeMetrics 在后台启动 /metrics 服务
+func ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(100 * time.Millisecond): // 监听成功
+		return nil
+	}
+}
+
+// 转义标签值
+func labelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+type metricWriter struct {
+	w    io.Writer
+	seen map[string]bool
+}
+
+func (m *metricWriter) write(name, help, typ string, labels map[string]string, value float64) {
+	if !m.seen[name] {
+		m.seen[name] = true
+		fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	if len(labels) == 0 {
+		fmt.Fprintf(m.w, "%s %g\n", name, value)
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, labelValue(labels[k])))
+	}
+	fmt.Fprintf(m.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出指标
+func WriteMetrics(w io.Writer) {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	m := &metricWriter{w: w, seen: make(map[string]bool)}
+
+	if !metricsRun.Start.IsZero() {
+		m.write("dockerst_run_timestamp_seconds", "Unix time the last run started.", "gauge", nil, float64(metricsRun.Start.Unix()))
+		m.write("dockerst_run_duration_seconds", "Duration of the last run.", "gauge", nil, metricsRun.Duration.Seconds())
+	}
+	for _, c := range []struct {
+		stage string
+		n     int
+	}{{"tested", metricsRun.Tested}, {"available", metricsRun.Available}, {"filtered", metricsRun.Filtered}, {"download", metricsRun.Download}} {
+		m.write("dockerst_candidates", "Number of candidate IPs per stage of the last run.", "gauge", map[string]string{"stage": c.stage}, float64(c.n))
+	}
+
+	// 同一指标的样本必须连续输出
+	for _, metric := range []struct {
+		name, help string
+		value      func(v *CloudflareIPData) float64
+	}{
+		{"dockerst_ip_latency_seconds", "Average latency of the IP.", func(v *CloudflareIPData) float64 { return v.Delay.Seconds() }},
+		{"dockerst_ip_jitter_seconds", "Latency jitter of the IP.", func(v *CloudflareIPData) float64 { return v.Jitter.Seconds() }},
+		{"dockerst_ip_loss_ratio", "Packet loss ratio of the IP.", func(v *CloudflareIPData) float64 { return float64(v.getLossRate()) }},
+		{"dockerst_ip_download_bytes_per_second", "Download speed of the IP.", func(v *CloudflareIPData) float64 { return v.DownloadSpeed }},
+		{"dockerst_ip_score", "Composite score of the IP.", func(v *CloudflareIPData) float64 { return v.Score }},
+	} {
+		for i := range metricsData {
+			v := &metricsData[i]
+			m.write(metric.name, metric.help, "gauge", map[string]string{"ip": v.IP.String(), "colo": v.Colo}, metric.value(v))
+		}
+	}
+
+	// 当前生效的 IP 以 hosts 中 DockerST 写入的部分为准，本次运行没有写入（演练、未找到可用 IP 等）时同样输出
+	for _, entry := range currentHostsEntries() {
+		labels := map[string]string{"ip": entry.IP, "domain": entry.Host}
+		if metricsAppliedData != nil && metricsAppliedData.IP.String() == entry.IP {
+			labels["colo"] = metricsAppliedData.Colo
+		}
+		m.write("dockerst_applied_info", "IP currently written to hosts by DockerST.", "gauge", labels, 1)
+	}
+	applied := 0.0
+	if metricsAppliedIP != "" {
+		applied = 1
+		if metricsAppliedData != nil {
+			m.write("dockerst_applied_latency_seconds", "Average latency of the applied IP.", "gauge", nil, metricsAppliedData.Delay.Seconds())
+			m.write("dockerst_applied_download_bytes_per_second", "Download speed of the applied IP.", "gauge", nil, metricsAppliedData.DownloadSpeed)
+		}
+	}
+	m.write("dockerst_applied", "Whether the last run applied an IP to hosts.", "gauge", nil, applied)
+}
+
+// 返回 hosts 中 DockerST 写入的记录，无法读取 hosts 时使用最近一次写入 hosts 的备份记录
+func currentHostsEntries() []HostIP {
+	if path, err := hostsPath(); err == nil {
+		if entries, err := managedHostsEntries(path); err == nil {
+			return entries
+		}
+	}
+	records, err := LoadBackups()
+	if err != nil {
+		return nil
+	}
+	return lastHostsChange(records)
+}
+
+// 最近一次修改 hosts 写入的记录，最近一次为还原或移除时返回空
+func lastHostsChange(records []BackupRecord) []HostIP {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Kind == BackupHosts {
+			return parseHostsEntries(records[i].Change)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagedHostsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	content := "127.0.0.1 localhost\n" +
+		"# DockerST Start\n" +
+		"104.16.1.1 docker.example.com\n" +
+		"104.16.1.2   registry-1.docker.io\n" +
+		"# DockerST End\n" +
+		"10.0.0.1 other.example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := managedHostsEntries(path)
+	want := []HostIP{{Host: "docker.example.com", IP: "104.16.1.1"}, {Host: "registry-1.docker.io", IP: "104.16.1.2"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("managedHostsEntries() = %v, %v, want %v", got, err, want)
+	}
+	if _, err := managedHostsEntries(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("managedHostsEntries(missing) = nil, want error")
+	}
+}
+
+func TestLastHostsChange(t *testing.T) {
+	records := []BackupRecord{
+		{Kind: BackupHosts, Change: "104.16.1.1 docker.example.com; 104.16.1.2 registry-1.docker.io"},
+		{Kind: BackupDocker, Change: "https://docker.example.com"},
+	}
+	want := []HostIP{{Host: "docker.example.com", IP: "104.16.1.1"}, {Host: "registry-1.docker.io", IP: "104.16.1.2"}}
+	if got := lastHostsChange(records); !reflect.DeepEqual(got, want) {
+		t.Errorf("lastHostsChange() = %v, want %v", got, want)
+	}
+	// 最近一次为还原时不知道当前内容
+	records = append(records, BackupRecord{Kind: BackupHosts, Change: "还原备份 20240101-000000"})
+	if got := lastHostsChange(records); got != nil {
+		t.Errorf("lastHostsChange(restored) = %v, want nil", got)
+	}
+}
+
+func TestWriteMetricsApplied(t *testing.T) {
+	setupApplyState(t)
+	t.Cleanup(func() { UpdateMetrics(nil, RunSummary{}) })
+	data := DownloadSpeedSet{{
+		PingData:      &PingData{IP: &net.IPAddr{IP: net.ParseIP("104.16.1.1")}, Sended: 4, Received: 4, Delay: 50 * time.Millisecond},
+		DownloadSpeed: 1024,
+	}}
+	AppliedIP, appliedData = "104.16.1.1", &data[0]
+	UpdateMetrics(data, RunSummary{Start: time.Now(), Download: 1})
+
+	// /metrics 与主流程并发：还原时修改 AppliedIP 不影响已更新的指标
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			WriteMetrics(io.Discard)
+		}
+	}()
+	AppliedIP, appliedData = "", nil
+	<-done
+
+	var buf bytes.Buffer
+	WriteMetrics(&buf)
+	for _, want := range []string{"dockerst_applied 1\n", "dockerst_applied_latency_seconds 0.05\n", "dockerst_applied_download_bytes_per_second 1024\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteMetrics() 缺少 %q:\n%s", want, buf.String())
+		}
+	}
+
+	UpdateMetrics(data, RunSummary{Start: time.Now(), Download: 1})
+	buf.Reset()
+	WriteMetrics(&buf)
+	if !strings.Contains(buf.String(), "dockerst_applied 0\n") {
+		t.Errorf("还原后 WriteMetrics() 应输出 dockerst_applied 0:\n%s", buf.String())
+	}
+}
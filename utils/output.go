@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 退出码
+const (
+	ExitApplied      = 0 // 已写入最优 IP 并设置加速器
+	ExitError        = 1 // 参数错误等其他错误
+	ExitNoUsableIP   = 2 // 没有可用的 IP
+	ExitApplyFailed  = 3 // 写入 hosts 或设置加速器失败
+	ExitProbeError   = 4 // 测速过程出错（如读取 IP 数据失败）
+	ExitVerifyFailed = 5 // 修改后验证未通过，已自动还原
+)
+
+var (
+	// Quiet 安静模式：不输出进度条、提示信息，不进行交互，结束时在标准输出打印一行 JSON 摘要
+	Quiet bool
+
+	// ErrNoUsableIP 没有可用的 IP
+	ErrNoUsableIP error = noUsableIPError{}
+)
+
+// 错误信息在输出时才翻译，包初始化时尚未确定语言
+type noUsableIPError struct{}
+
+func (noUsableIPError) Error() string {
+	return T("未找到可用的 IP")
+}
+
+// Printf 输出提示信息，安静模式下不输出
+func Printf(format string, a ...any) {
+	if !Quiet {
+		fmt.Printf(format, a...)
+	}
+}
+
+// Println 输出提示信息，安静模式下不输出
+func Println(a ...any) {
+	if !Quiet {
+		fmt.Println(a...)
+	}
+}
+
+// Print 输出提示信息，安静模式下不输出
+func Print(a ...any) {
+	if !Quiet {
+		fmt.Print(a...)
+	}
+}
+
+// Summary 安静模式下输出的 JSON 摘要，字段名保持稳定
+type Summary struct {
+	Schema        string            `json:"schema"`
+	Version       int               `json:"schema_version"`
+	Status        string            `json:"status"` // applied、dry_run、no_usable_ip、apply_failed、probe_error、verify_failed、error
+	ExitCode      int               `json:"exit_code"`
+	Error         string            `json:"error,omitempty"`
+	AppliedIP     string            `json:"applied_ip,omitempty"`
+	Domain        string            `json:"domain,omitempty"`
+	Hosts         map[string]string `json:"hosts,omitempty"` // 其他目标域名写入的 IP
+	DockerURL     string            `json:"docker_url"`
+	StartedAt     string            `json:"started_at,omitempty"`
+	DurationSec   float64           `json:"duration_sec"`
+	DownloadBytes int64             `json:"download_bytes"` // 下载测速消耗的总流量
+	Candidates    map[string]int    `json:"candidates,omitempty"`
+	Best          *ResultRecord     `json:"best,omitempty"`
+	Params        RunParams         `json:"params"`
+}
+
+func exitStatus(code int) string {
+	switch code {
+	case ExitApplied:
+		return "applied"
+	case ExitNoUsableIP:
+		return "no_usable_ip"
+	case ExitApplyFailed:
+		return "apply_failed"
+	case ExitProbeError:
+		return "probe_error"
+	case ExitVerifyFailed:
+		return "verify_failed"
+	default:
+		return "error"
+	}
+}
+
+// ExitCode 根据 DockerSet 的返回值得到退出码
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitApplied
+	case errors.Is(err, ErrNoUsableIP):
+		return ExitNoUsableIP
+	case errors.Is(err, ErrVerifyFailed):
+		return ExitVerifyFailed
+	default:
+		return ExitApplyFailed
+	}
+}
+
+// 安静模式下输出的 JSON 摘要
+func newSummary(code int, err error, data DownloadSpeedSet, run RunSummary) Summary {
+	summary := Summary{
+		Schema:        "dockerst.summary",
+		Version:       ResultSchemaVersion,
+		Status:        exitStatus(code),
+		ExitCode:      code,
+		AppliedIP:     AppliedIP,
+		DockerURL:     DefaultDockerUrl,
+		Params:        Params,
+		DownloadBytes: DownloadBytes,
+	}
+	if DryRun && code == ExitApplied {
+		summary.Status = "dry_run"
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	if AppliedIP != "" {
+		summary.Domain = DockerDomain()
+		for _, h := range HostIPs {
+			if summary.Hosts == nil {
+				summary.Hosts = make(map[string]string)
+			}
+			summary.Hosts[h.Host] = h.IP
+		}
+	}
+	if !run.Start.IsZero() {
+		summary.StartedAt = run.Start.Format(time.RFC3339)
+		summary.DurationSec = round2(run.Duration.Seconds())
+		summary.Candidates = map[string]int{"tested": run.Tested, "available": run.Available, "filtered": run.Filtered, "download": run.Download}
+	}
+	for i := range data { // 与 DockerSet 一致，为第一个可用的 IP
+		if !data[i].downloadFailed() {
+			best := data[i].toRecord()
+			summary.Best = &best
+			break
+		}
+	}
+	return summary
+}
+
+// Finish 结束运行：安静模式下在标准输出打印 JSON 摘要，然后以 code 退出
+func Finish(code int, err error, data DownloadSpeedSet, run RunSummary) {
+	if Quiet {
+		b, _ := json.Marshal(newSummary(code, err, data, run))
+		fmt.Println(string(b))
+	} else if err != nil && (code == ExitProbeError || code == ExitError) { // 其他错误已在发生时输出
+		fmt.Println(T("[错误]"), err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		code   int
+		status string
+	}{
+		{nil, ExitApplied, "applied"},
+		{ErrNoUsableIP, ExitNoUsableIP, "no_usable_ip"},
+		{fmt.Errorf("wrap: %w", ErrVerifyFailed), ExitVerifyFailed, "verify_failed"},
+		{errors.New("permission denied"), ExitApplyFailed, "apply_failed"},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.code || exitStatus(got) != tt.status {
+			t.Errorf("ExitCode(%v) = %d (%s), want %d (%s)", tt.err, got, exitStatus(got), tt.code, tt.status)
+		}
+	}
+	if got := exitStatus(ExitProbeError); got != "probe_error" {
+		t.Errorf("exitStatus(ExitProbeError) = %s", got)
+	}
+	if got := exitStatus(ExitError); got != "error" {
+		t.Errorf("exitStatus(ExitError) = %s", got)
+	}
+}
+
+func TestQuietOutput(t *testing.T) {
+	setupApplyState(t)
+	if out := captureStdout(t, func() {
+		Printf("a %d\n", 1)
+		Println("b")
+		Print("c")
+	}); out != "" {
+		t.Errorf("安静模式下输出了 %q", out)
+	}
+	Quiet = false
+	if out := captureStdout(t, func() {
+		Printf("a %d\n", 1)
+		Println("b")
+		Print("c")
+	}); out != "a 1\nb\nc" {
+		t.Errorf("输出 = %q", out)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	setupApplyState(t)
+	oldDryRun := DryRun
+	defer func() { DryRun = oldDryRun }()
+	data := testSpeedSet()
+	data[0].Trace = &TraceData{} // 下载测速失败
+	data[0].DownloadSpeed = 0
+	AppliedIP = "1.1.1.2"
+	run := RunSummary{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Duration: 1500 * time.Millisecond, Tested: 10, Available: 5, Filtered: 3, Download: 3}
+
+	summary := newSummary(ExitApplied, nil, data, run)
+	if summary.Status != "applied" || summary.AppliedIP != "1.1.1.2" || summary.Domain != DockerDomain() || summary.DurationSec != 1.5 {
+		t.Errorf("newSummary() = %+v", summary)
+	}
+	// 与 DockerSet 一致，跳过下载测速失败的 IP
+	if summary.Best == nil || summary.Best.IP != "1.1.1.2" {
+		t.Errorf("Best = %+v, want 1.1.1.2", summary.Best)
+	}
+	b, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema", "schema_version", "status", "exit_code", "applied_ip", "docker_url", "started_at", "candidates", "best", "params"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("JSON 摘要缺少 %s: %s", key, b)
+		}
+	}
+
+	DryRun = true
+	if summary = newSummary(ExitApplied, nil, data, run); summary.Status != "dry_run" {
+		t.Errorf("演练模式 Status = %s", summary.Status)
+	}
+	DryRun, AppliedIP = false, ""
+	summary = newSummary(ExitNoUsableIP, ErrNoUsableIP, nil, RunSummary{})
+	if summary.Status != "no_usable_ip" || summary.Error == "" || summary.Best != nil || summary.Domain != "" || summary.StartedAt != "" {
+		t.Errorf("newSummary(no usable ip) = %+v", summary)
+	}
+}
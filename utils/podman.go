@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+const (
+	// BackupPodman Podman、Buildah、CRI-O 共用的 registries.conf 的备份
+	BackupPodman = "podman"
+
+	dockerHubPrefix = "docker.io"
+	// 没有文件配置 docker.io 时新建的 registries.conf.d 配置片段
+	podmanDropIn = "dockerst.conf"
+)
+
+// ContainersConfDir Podman、Buildah、CRI-O 的配置目录（registries.conf 及 registries.conf.d 所在目录）
+var ContainersConfDir = "/etc/containers"
+
+// Podman / CRI-O：registries.conf 中 docker.io 的 [[registry]] 下的 [[registry.mirror]]
+type podmanRuntime struct{}
+
+func (podmanRuntime) Name() string {
+	return BackupPodman
+}
+
+func (podmanRuntime) Detect() bool {
+	for _, name := range []string{"podman", "crio", "buildah"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// 配置片段会整体覆盖之前文件中相同 prefix 的 [[registry]]，因此修改最后一个配置了 docker.io 的文件
+// 都没有配置时新建配置片段，不改动管理员的 registries.conf；其中有 v1 格式的文件时返回错误
+func (podmanRuntime) ConfigPath() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
+	}
+	dropIns, _ := filepath.Glob(filepath.Join(ContainersConfDir, "registries.conf.d", "*.conf"))
+	slices.Sort(dropIns)
+	configPath := filepath.Join(ContainersConfDir, "registries.conf.d", podmanDropIn)
+	for _, path := range append([]string{filepath.Join(ContainersConfDir, "registries.conf")}, dropIns...) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		blocks, err := parseRegistriesConf(string(content))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		if dockerHubRegistry(blocks) >= 0 {
+			configPath = path
+		}
+	}
+	return configPath, nil
+}
+
+func (podmanRuntime) SetMirror(configPath, dockerUrl string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf(T("无法打开 registries.conf 配置文件: %w"), err)
+	}
+	blocks, err := parseRegistriesConf(string(content))
+	if err != nil {
+		return fmt.Errorf("%s: %w", configPath, err)
+	}
+	i := dockerHubRegistry(blocks)
+	if i < 0 {
+		blocks = append(blocks, registryBlock{registry: true, prefix: dockerHubPrefix, lines: []string{
+			"[[registry]]",
+			fmt.Sprintf("prefix = %q", dockerHubPrefix),
+			fmt.Sprintf("location = %q", dockerHubPrefix),
+		}})
+		i = len(blocks) - 1
+	}
+	location := mirrorLocation(dockerUrl)
+	old := hostURLs(blocks[i].mirrors)
+	mirrors := mergeMirrors(old, location, MirrorMode)
+	if slices.Equal(old, mirrors) {
+		Println(T("\n[信息] registries.conf 配置文件中的加速器已是最新，无需修改。"))
+		return nil
+	}
+
+	// 按合并后的顺序排列 [[registry.mirror]]，加速器使用新的配置
+	var sections []tomlHostSection
+	for _, m := range mirrors {
+		if m == location {
+			lines := []string{"[[registry.mirror]]", fmt.Sprintf("location = %q", location)}
+			if strings.HasPrefix(dockerUrl, "http://") {
+				lines = append(lines, "insecure = true")
+			}
+			sections = append(sections, tomlHostSection{url: location, lines: lines})
+			continue
+		}
+		for _, s := range blocks[i].mirrors {
+			if s.url == m {
+				sections = append(sections, s)
+			}
+		}
+	}
+	blocks[i].mirrors = sections
+	if !DryRun {
+		if err = os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return err
+		}
+	}
+	if err = applyFile(BackupPodman, configPath, dockerUrl, renderRegistriesConf(blocks)); err != nil {
+		return fmt.Errorf(T("写入 registries.conf 配置文件出错: %w"), err)
+	}
+	if DryRun {
+		return nil
+	}
+
+	Println(T("\n[信息] 成功更新 registries.conf 配置文件，加速器变更："))
+	for _, line := range mirrorDiff(old, mirrors) {
+		Println("  " + line)
+	}
+	return nil
+}
+
+func (podmanRuntime) PurgeMirror(configPath string, isOurs func(mirror string) bool) (bool, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	blocks, err := parseRegistriesConf(string(content))
+	if err != nil { // v1 格式的文件不会是 DockerST 修改的
+		return false, nil
+	}
+	changed := false
+	for i := range blocks {
+		var kept []tomlHostSection
+		for _, m := range blocks[i].mirrors {
+			// location 中没有协议，备份记录中的加速器地址有
+			if isOurs("https://"+m.url) || isOurs("http://"+m.url) {
+				changed = true
+			} else {
+				kept = append(kept, m)
+			}
+		}
+		blocks[i].mirrors = kept
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, applyFile(BackupPodman, configPath, T("移除 DockerST 修改"), renderRegistriesConf(blocks))
+}
+
+// Podman、Buildah 每次运行时都会读取 registries.conf，只有 CRI-O 需要重新加载配置
+func (podmanRuntime) RestartCommand() (*exec.Cmd, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf(T("不支持的操作系统：%s"), runtime.GOOS)
+	}
+	if _, err := exec.LookPath("crio"); err != nil {
+		return nil, nil
+	}
+	return exec.Command("systemctl", "reload", "crio"), nil
+}
+
+// registries.conf 中的一个 [[registry]] 表及其 [[registry.mirror]]，或者其他内容
+type registryBlock struct {
+	registry bool
+	prefix   string // prefix，未设置时为 location
+	lines    []string
+	mirrors  []tomlHostSection // url 为 [[registry.mirror]] 的 location
+}
+
+// 将 registries.conf 按表拆分，只做简单的按行解析，[[registry]] 以外的内容原样保留
+// v1 格式（[registries.search] 等）不支持 [[registry.mirror]]，且不能与 v2 格式混用，返回错误
+func parseRegistriesConf(content string) ([]registryBlock, error) {
+	var blocks []registryBlock
+	inMirror := false
+	for _, line := range strings.Split(strings.TrimRight(content, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "[registries."):
+			return nil, errors.New(T("registries.conf 为 v1 格式，不支持配置加速器，请先转换为 v2 格式（[[registry]]）"))
+		case trimmed == "[[registry]]":
+			blocks = append(blocks, registryBlock{registry: true})
+			inMirror = false
+		case trimmed == "[[registry.mirror]]" && len(blocks) > 0 && blocks[len(blocks)-1].registry:
+			block := &blocks[len(blocks)-1]
+			block.mirrors = append(block.mirrors, tomlHostSection{lines: []string{line}})
+			inMirror = true
+			continue
+		case strings.HasPrefix(trimmed, "[") || len(blocks) == 0:
+			blocks = append(blocks, registryBlock{})
+			inMirror = false
+		}
+		block := &blocks[len(blocks)-1]
+		key, value := tomlKeyValue(trimmed)
+		if inMirror {
+			mirror := &block.mirrors[len(block.mirrors)-1]
+			mirror.lines = append(mirror.lines, line)
+			if key == "location" {
+				mirror.url = value
+			}
+			continue
+		}
+		block.lines = append(block.lines, line)
+		if block.registry && (key == "prefix" || key == "location" && block.prefix == "") {
+			block.prefix = value
+		}
+	}
+	return blocks, nil
+}
+
+func renderRegistriesConf(blocks []registryBlock) []byte {
+	trim := func(lines []string) []string {
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		return lines
+	}
+	var b strings.Builder
+	for _, block := range blocks {
+		sections := [][]string{block.lines}
+		for _, m := range block.mirrors {
+			sections = append(sections, m.lines)
+		}
+		for _, lines := range sections {
+			if lines = trim(lines); len(lines) == 0 {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			for _, line := range lines {
+				b.WriteString(line + "\n")
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// 返回 docker.io 的 [[registry]] 的下标，没有时返回 -1
+func dockerHubRegistry(blocks []registryBlock) int {
+	for i, block := range blocks {
+		if block.registry && (block.prefix == dockerHubPrefix || block.prefix == "registry-1.docker.io") {
+			return i
+		}
+	}
+	return -1
+}
+
+// 加速器地址去掉协议及末尾的 / 后作为 [[registry.mirror]] 的 location
+func mirrorLocation(dockerUrl string) string {
+	if _, rest, ok := strings.Cut(dockerUrl, "://"); ok {
+		dockerUrl = rest
+	}
+	return strings.TrimRight(dockerUrl, "/")
+}
+
+// 解析 key = "value" 形式的行，不是键值对时返回空
+func tomlKeyValue(line string) (key, value string) {
+	k, v, ok := strings.Cut(line, "=")
+	if !ok || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	v = strings.TrimSpace(v)
+	if v != "" && (v[0] == '"' || v[0] == '\'') {
+		if end := strings.IndexByte(v[1:], v[0]); end >= 0 {
+			return strings.TrimSpace(k), v[1 : end+1]
+		}
+	}
+	if i := strings.Index(v, "#"); i >= 0 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return strings.TrimSpace(k), v
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testRegistriesConf = `unqualified-search-registries = ["docker.io"]
+
+[[registry]]
+prefix = "quay.io"
+location = "quay.io"
+
+[[registry]]
+prefix = "docker.io"
+location = "docker.io"
+
+[[registry.mirror]]
+location = "a.example.com" # 注释
+insecure = true
+
+[[registry.mirror]]
+location = 'b.example.com'
+`
+
+func TestParseRegistriesConf(t *testing.T) {
+	blocks, err := parseRegistriesConf(testRegistriesConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Fatalf("len(blocks) = %d, want 3", len(blocks))
+	}
+	if blocks[0].registry || !blocks[1].registry || blocks[1].prefix != "quay.io" {
+		t.Errorf("blocks = %+v", blocks)
+	}
+	i := dockerHubRegistry(blocks)
+	if i != 2 {
+		t.Fatalf("dockerHubRegistry() = %d, want 2", i)
+	}
+	if got, want := hostURLs(blocks[i].mirrors), []string{"a.example.com", "b.example.com"}; !slices.Equal(got, want) {
+		t.Errorf("mirrors = %v, want %v", got, want)
+	}
+	if got := string(renderRegistriesConf(blocks)); got != testRegistriesConf {
+		t.Errorf("renderRegistriesConf() =\n%s\nwant\n%s", got, testRegistriesConf)
+	}
+}
+
+func TestDockerHubRegistryMissing(t *testing.T) {
+	blocks, _ := parseRegistriesConf("[[registry]]\nlocation = \"quay.io\"\n")
+	if i := dockerHubRegistry(blocks); i != -1 {
+		t.Errorf("dockerHubRegistry() = %d, want -1", i)
+	}
+}
+
+func TestMirrorLocation(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://a.example.com/":    "a.example.com",
+		"http://a.example.com:5000": "a.example.com:5000",
+		"a.example.com/path/":       "a.example.com/path",
+	} {
+		if got := mirrorLocation(in); got != want {
+			t.Errorf("mirrorLocation(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseRegistriesConfV1(t *testing.T) {
+	const v1 = `[registries.search]
+registries = ['docker.io', 'quay.io']
+
+[registries.insecure]
+registries = []
+`
+	if _, err := parseRegistriesConf(v1); err == nil {
+		t.Fatal("parseRegistriesConf(v1) = nil, want error")
+	}
+
+	// 不能修改 v1 格式的文件
+	path := filepath.Join(t.TempDir(), "registries.conf")
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (podmanRuntime{}).SetMirror(path, "https://a.example.com"); err == nil {
+		t.Error("SetMirror(v1) = nil, want error")
+	}
+	if content, _ := os.ReadFile(path); string(content) != v1 {
+		t.Errorf("registries.conf 被修改为 %q", content)
+	}
+	if changed, err := (podmanRuntime{}).PurgeMirror(path, func(string) bool { return true }); changed || err != nil {
+		t.Errorf("PurgeMirror(v1) = %v, %v", changed, err)
+	}
+}
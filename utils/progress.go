@@ -10,16 +10,26 @@ type Bar struct {
 	pb *pb.ProgressBar
 }
 
+// 安静模式下返回不输出的进度条
 func NewBar(count int, MyStrStart, MyStrEnd string) *Bar {
+	if Quiet {
+		return &Bar{}
+	}
 	tmpl := fmt.Sprintf(`{{counters . }} {{ bar . "[" "-" (cycle . "↖" "↗" "↘" "↙" ) "_" "]"}} %s {{string . "MyStr" | green}} %s `, MyStrStart, MyStrEnd)
 	bar := pb.ProgressBarTemplate(tmpl).Start(count)
 	return &Bar{pb: bar}
 }
 
 func (b *Bar) Grow(num int, MyStrVal string) {
+	if b.pb == nil {
+		return
+	}
 	b.pb.Set("MyStr", MyStrVal).Add(num)
 }
 
 func (b *Bar) Done() {
+	if b.pb == nil {
+		return
+	}
 	b.pb.Finish()
 }
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// HTMLOutput HTML 报告文件，为空则不输出
+var HTMLOutput string
+
+type reportBucket struct {
+	Label   string
+	Count   int
+	Percent float64 // 相对最大桶的宽度百分比
+}
+
+type reportColo struct {
+	Colo      string
+	Count     int
+	AvgDelay  float64
+	AvgSpeed  float64
+	BestSpeed float64
+}
+
+type reportData struct {
+	ResultFile
+	AppliedIP     string
+	AppliedDomain string
+	DelayBuckets  []reportBucket
+	SpeedBuckets  []reportBucket
+	Colos         []reportColo
+}
+
+// 将数值分为 n 个等宽区间并统计数量
+func buckets(values []float64, n int, unit string) []reportBucket {
+	if len(values) == 0 {
+		return nil
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	width := (hi - lo) / float64(n)
+	if width == 0 {
+		return []reportBucket{{Label: fmt.Sprintf("%.2f %s", lo, unit), Count: len(values), Percent: 100}}
+	}
+	result := make([]reportBucket, n)
+	for i := range result {
+		result[i].Label = fmt.Sprintf("%.2f ~ %.2f %s", lo+width*float64(i), lo+width*float64(i+1), unit)
+	}
+	maxCount := 0
+	for _, v := range values {
+		i := int((v - lo) / width)
+		if i >= n {
+			i = n - 1
+		}
+		result[i].Count++
+		maxCount = max(maxCount, result[i].Count)
+	}
+	for i := range result {
+		result[i].Percent = float64(result[i].Count) / float64(maxCount) * 100
+	}
+	return result
+}
+
+func newReportData(data []CloudflareIPData) reportData {
+	r := reportData{ResultFile: NewResultFile(data), AppliedIP: AppliedIP}
+	if AppliedIP != "" {
+		r.AppliedDomain = DockerDomain()
+	}
+	var delays, speeds []float64
+	colos := make(map[string]*reportColo)
+	for _, v := range r.Results {
+		delays = append(delays, v.DelayMs)
+		if v.DownloadMBps > 0 {
+			speeds = append(speeds, v.DownloadMBps)
+		}
+		name := v.Colo
+		if name == "" {
+			name = "-"
+		}
+		c, ok := colos[name]
+		if !ok {
+			c = &reportColo{Colo: name}
+			colos[name] = c
+		}
+		c.Count++
+		c.AvgDelay += v.DelayMs
+		c.AvgSpeed += v.DownloadMBps
+		c.BestSpeed = math.Max(c.BestSpeed, v.DownloadMBps)
+	}
+	r.DelayBuckets = buckets(delays, 10, "ms")
+	r.SpeedBuckets = buckets(speeds, 10, "MB/s")
+	for _, c := range colos {
+		c.AvgDelay /= float64(c.Count)
+		c.AvgSpeed /= float64(c.Count)
+		r.Colos = append(r.Colos, *c)
+	}
+	sort.Slice(r.Colos, func(i, j int) bool {
+		if r.Colos[i].Count != r.Colos[j].Count {
+			return r.Colos[i].Count > r.Colos[j].Count
+		}
+		return r.Colos[i].Colo < r.Colos[j].Colo
+	})
+	return r
+}
+
+// ExportHTML 输出单文件 HTML 报告（不依赖任何外部资源）
+func ExportHTML(data []CloudflareIPData) {
+	if HTMLOutput == "" || len(data) == 0 {
+		return
+	}
+	fp, err := os.Create(HTMLOutput)
+	if err != nil {
+		Printf(T("\n[错误] 创建文件[%s]失败：%v\n"), HTMLOutput, err)
+		return
+	}
+	defer fp.Close()
+	if err = reportTemplate.Execute(fp, newReportData(data)); err != nil {
+		Printf(T("\n[错误] 写入 HTML 报告失败：%v\n"), err)
+		return
+	}
+	Printf(T("\nHTML 报告已写入 %v 文件，可使用浏览器打开查看。\n"), HTMLOutput)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"T":    T,
+	"lang": func() string { return Lang },
+}).Parse(`<!DOCTYPE html>
+<html lang="{{lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{T "DockerST 测速报告"}} {{time .GeneratedAt}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI","PingFang SC","Microsoft YaHei",sans-serif;margin:2em auto;max-width:1100px;padding:0 1em;color:#222}
+h1{font-size:1.6em}h2{font-size:1.2em;margin-top:2em;border-bottom:1px solid #ddd;padding-bottom:.3em}
+table{border-collapse:collapse;width:100%;font-size:.9em}
+th,td{border:1px solid #ddd;padding:.35em .6em;text-align:right}
+th:first-child,td:first-child{text-align:left}
+th{background:#f4f6f8;cursor:pointer;user-select:none}
+th.asc::after{content:" ▲"}th.desc::after{content:" ▼"}
+tr.applied{background:#e8f6ea;font-weight:bold}
+.summary{display:flex;flex-wrap:wrap;gap:1em}
+.card{background:#f4f6f8;border-radius:6px;padding:.8em 1.2em;min-width:12em}
+.card b{display:block;font-size:1.3em}
+.params td,.params th{text-align:left}
+.bar{display:flex;align-items:center;margin:.2em 0;font-size:.85em}
+.bar span{width:14em;flex:none}
+.bar div{background:#4a90d9;height:1em;margin-right:.5em}
+.muted{color:#888}
+</style>
+</head>
+<body>
+<h1>{{T "DockerST 测速报告"}}</h1>
+<div class="summary">
+<div class="card">{{T "写入 hosts 的 IP"}}<b>{{if .AppliedIP}}{{.AppliedIP}}{{else}}{{T "未写入"}}{{end}}</b>{{if .AppliedDomain}}<span class="muted">{{.AppliedDomain}}</span>{{end}}</div>
+<div class="card">{{T "测速时间"}}<b>{{time .GeneratedAt}}</b></div>
+<div class="card">{{T "结果数量"}}<b>{{len .Results}}</b></div>
+</div>
+
+<h2>{{T "运行参数"}}</h2>
+<table class="params">
+<tr><th>{{T "版本"}}</th><td>{{.Params.Version}}</td></tr>
+<tr><th>{{T "延迟测速"}}</th><td>{{printf (T "%s，端口 %d，协议 %s，线程 %d，次数 %d") .Params.Mode .Params.Port .Params.Protocol .Params.Routines .Params.PingTimes}}</td></tr>
+<tr><th>{{T "过滤条件"}}</th><td>{{printf (T "延迟 %v ~ %v ms，丢包率上限 %v，下载速度下限 %v MB/s") .Params.MinDelayMs .Params.MaxDelayMs .Params.MaxLossRate .Params.MinSpeedMBps}}</td></tr>
+<tr><th>{{T "下载测速"}}</th><td>{{printf (T "%s，数量 %d，时间 %v 秒") .Params.URL .Params.TestCount .Params.DownloadTimeSec}}</td></tr>
+<tr><th>{{T "排序"}}</th><td>{{printf (T "%s（权重 %s）") .Params.SortKey .Params.Weights}}</td></tr>
+<tr><th>{{T "Docker 地址"}}</th><td>{{.Params.DockerURL}}</td></tr>
+</table>
+
+<h2>{{T "测速结果"}}</h2>
+<p class="muted">{{T "点击表头排序。"}}</p>
+<table id="results">
+<thead><tr><th>{{T "IP 地址"}}</th><th>{{T "地区"}}</th><th>{{T "已发送"}}</th><th>{{T "已接收"}}</th><th>{{T "丢包率"}}</th><th>{{T "平均延迟 (ms)"}}</th><th>{{T "抖动 (ms)"}}</th><th>{{T "下载速度 (MB/s)"}}</th><th>{{T "协议"}}</th><th>{{T "得分"}}</th></tr></thead>
+<tbody>
+{{range .Results}}<tr{{if eq .IP $.AppliedIP}} class="applied"{{end}}><td>{{.IP}}</td><td>{{.Colo}}</td><td>{{.Sent}}</td><td>{{.Received}}</td><td>{{printf "%.2f" .LossRate}}</td><td>{{printf "%.2f" .DelayMs}}</td><td>{{printf "%.2f" .JitterMs}}</td><td>{{printf "%.2f" .DownloadMBps}}</td><td>{{.Protocol}}</td><td>{{printf "%.2f" .Score}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>{{T "延迟分布"}}</h2>
+{{range .DelayBuckets}}<div class="bar"><span>{{.Label}}</span><div style="width:{{printf "%.1f" .Percent}}%"></div>{{.Count}}</div>
+{{end}}
+<h2>{{T "下载速度分布"}}</h2>
+{{range .SpeedBuckets}}<div class="bar"><span>{{.Label}}</span><div style="width:{{printf "%.1f" .Percent}}%"></div>{{.Count}}</div>
+{{else}}<p class="muted">{{T "没有下载测速结果。"}}</p>
+{{end}}
+<h2>{{T "地区统计"}}</h2>
+<table>
+<thead><tr><th>{{T "地区"}}</th><th>{{T "数量"}}</th><th>{{T "平均延迟 (ms)"}}</th><th>{{T "平均下载速度 (MB/s)"}}</th><th>{{T "最高下载速度 (MB/s)"}}</th></tr></thead>
+<tbody>
+{{range .Colos}}<tr><td>{{.Colo}}</td><td>{{.Count}}</td><td>{{printf "%.2f" .AvgDelay}}</td><td>{{printf "%.2f" .AvgSpeed}}</td><td>{{printf "%.2f" .BestSpeed}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<script>
+document.querySelectorAll("#results th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#results tbody");
+    var asc = !th.classList.contains("asc");
+    document.querySelectorAll("#results th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(asc ? "asc" : "desc");
+    var rows = Array.prototype.slice.call(tbody.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[col].textContent, y = b.cells[col].textContent;
+      var nx = parseFloat(x), ny = parseFloat(y);
+      var r = (!isNaN(nx) && !isNaN(ny) && col > 1) ? nx - ny : x.localeCompare(y, undefined, {numeric: true});
+      return asc ? r : -r;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
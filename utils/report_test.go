@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuckets(t *testing.T) {
+	got := buckets([]float64{0, 1, 2, 9, 10}, 5, "ms")
+	if len(got) != 5 || got[0].Count != 2 || got[1].Count != 1 || got[4].Count != 2 { // 最大值计入最后一个区间
+		t.Fatalf("buckets() = %+v", got)
+	}
+	if got[0].Label != "0.00 ~ 2.00 ms" || got[0].Percent != 100 || got[1].Percent != 50 {
+		t.Errorf("buckets()[0] = %+v, [1] = %+v", got[0], got[1])
+	}
+	if got := buckets([]float64{3, 3}, 5, "ms"); len(got) != 1 || got[0].Count != 2 { // 所有值相同
+		t.Errorf("buckets(same) = %+v", got)
+	}
+	if got := buckets(nil, 5, "ms"); got != nil {
+		t.Errorf("buckets(nil) = %+v", got)
+	}
+}
+
+func TestExportHTML(t *testing.T) {
+	setupApplyState(t)
+	oldOutput := HTMLOutput
+	HTMLOutput = filepath.Join(t.TempDir(), "report.html")
+	defer func() { HTMLOutput = oldOutput }()
+	data := testSpeedSet()
+	data[0].Colo, data[1].Colo, data[2].Colo = "HKG", "HKG", "NRT"
+	data[2].DownloadSpeed = 0
+	AppliedIP = "1.1.1.3"
+
+	ExportHTML(data)
+	content, err := os.ReadFile(HTMLOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(content)
+	for _, want := range []string{
+		`<tr class="applied"><td>1.1.1.3</td><td>NRT</td>`, // 写入 hosts 的 IP 高亮
+		`<tr><td>HKG</td><td>2</td><td>150.00</td><td>7.50</td><td>10.00</td></tr>`,
+		`<div style="width:100.0%">`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML 报告缺少 %q", want)
+		}
+	}
+	// 单文件报告，不引用外部资源
+	for _, bad := range []string{"<link", "<script src", "ZgotmplZ"} {
+		if strings.Contains(html, bad) {
+			t.Errorf("HTML 报告包含 %q", bad)
+		}
+	}
+
+	r := newReportData(data)
+	if len(r.SpeedBuckets) != 10 || r.SpeedBuckets[0].Count+r.SpeedBuckets[9].Count != 2 { // 未下载测速的 IP 不计入速度分布
+		t.Errorf("SpeedBuckets = %+v", r.SpeedBuckets)
+	}
+}
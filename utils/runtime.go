@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 重启后等待服务恢复的最长时间
+const healthTimeout = 30 * time.Second
+
+var (
+	// RuntimeName 写入加速器的容器运行时：auto（自动检测）或 Runtimes 中的名称
+	RuntimeName = "auto"
+	// RestartPolicy 设置加速器后是否重启服务：never（不重启）、always（总是重启）、ask（有终端时询问，否则不重启）
+	RestartPolicy = "ask"
+)
+
+// Runtime 容器运行时的加速器配置方式
+type Runtime interface {
+	// Name 运行时名称，同时作为备份记录的类型
+	Name() string
+	// Detect 当前系统是否在使用该运行时
+	Detect() bool
+	// ConfigPath 加速器配置文件的路径
+	ConfigPath() (string, error)
+	// SetMirror 将加速器地址合并到配置文件中
+	SetMirror(configPath, dockerUrl string) error
+	// PurgeMirror 从配置文件中移除 isOurs 返回 true 的加速器地址，返回是否修改了文件
+	PurgeMirror(configPath string, isOurs func(mirror string) bool) (bool, error)
+	// RestartCommand 使配置生效的命令，不需要时返回 nil
+	RestartCommand() (*exec.Cmd, error)
+}
+
+// 可以检查服务状态的容器运行时，重启后等待其恢复
+type healthChecker interface {
+	// Health 服务正常时返回其版本
+	Health() (string, error)
+}
+
+// Runtimes 支持的容器运行时，自动检测时按顺序选择第一个检测到的
+var Runtimes = []Runtime{dockerRuntime{}, containerdRuntime{}, podmanRuntime{}}
+
+// CheckRuntime 检查指定的容器运行时是否支持
+func CheckRuntime() error {
+	if RuntimeName == "auto" || findRuntime(RuntimeName) != nil {
+		return nil
+	}
+	return fmt.Errorf(T("未知的容器运行时：%s（可选 auto/%s）"), RuntimeName, strings.Join(runtimeNames(), "/"))
+}
+
+// CheckRestartPolicy 检查重启策略
+func CheckRestartPolicy() error {
+	switch RestartPolicy {
+	case "never", "always", "ask":
+		return nil
+	default:
+		return fmt.Errorf(T("未知的重启策略：%s（可选 never、always、ask）"), RestartPolicy)
+	}
+}
+
+func runtimeNames() []string {
+	var names []string
+	for _, rt := range Runtimes {
+		names = append(names, rt.Name())
+	}
+	return names
+}
+
+func findRuntime(name string) Runtime {
+	for _, rt := range Runtimes {
+		if rt.Name() == name {
+			return rt
+		}
+	}
+	return nil
+}
+
+// SelectRuntime 返回指定的容器运行时，自动检测时返回第一个检测到的
+func SelectRuntime() (Runtime, error) {
+	if RuntimeName != "auto" {
+		if rt := findRuntime(RuntimeName); rt != nil {
+			return rt, nil
+		}
+		return nil, CheckRuntime()
+	}
+	for _, rt := range Runtimes {
+		if rt.Detect() {
+			return rt, nil
+		}
+	}
+	return nil, errors.New(T("未检测到支持的容器运行时（可使用 -runtime 参数指定）"))
+}
+
+// NeedsRestart 本次运行修改配置后，指定的容器运行时是否需要重启才能生效
+// 无法确定重启命令时返回 true，由 RestartRuntime 报告原因
+func NeedsRestart(name string) bool {
+	rt := findRuntime(name)
+	if rt == nil {
+		return false
+	}
+	cmd, err := rt.RestartCommand()
+	return err != nil || cmd != nil
+}
+
+// RestartRuntime 重启指定的容器运行时使配置生效
+func RestartRuntime(name string) error {
+	rt := findRuntime(name)
+	if rt == nil {
+		return fmt.Errorf(T("未知的容器运行时：%s（可选 auto/%s）"), name, strings.Join(runtimeNames(), "/"))
+	}
+	cmd, err := rt.RestartCommand()
+	if err != nil || cmd == nil {
+		return err
+	}
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf(T("重启 %s 服务失败: %w"), name, err)
+	}
+	Printf(T("[信息] 已执行：%s\n"), cmd)
+	restartedRuntime = name
+	hc, ok := rt.(healthChecker)
+	if !ok {
+		return nil
+	}
+	Printf(T("[信息] 等待 %s 服务恢复...\n"), name)
+	deadline := time.Now().Add(healthTimeout)
+	for {
+		version, err := hc.Health()
+		if err == nil {
+			Printf(T("[信息] %s 服务运行正常（版本 %s）。\n"), name, version)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf(T("%s 服务在 %v 内未恢复: %w"), name, healthTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// 文件是否为终端（不是管道、普通文件或 /dev/null）
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// Docker：daemon.json 中的 registry-mirrors
+type dockerRuntime struct{}
+
+func (dockerRuntime) Name() string {
+	return BackupDocker
+}
+
+func (dockerRuntime) Detect() bool {
+	return isDockerInstalled()
+}
+
+func (dockerRuntime) ConfigPath() (string, error) {
+	return dockerConfigPath()
+}
+
+func (dockerRuntime) SetMirror(configPath, dockerUrl string) error {
+	return updateDockerConfig(configPath, dockerUrl)
+}
+
+func (dockerRuntime) PurgeMirror(configPath string, isOurs func(mirror string) bool) (bool, error) {
+	config, err := readDockerConfig(configPath)
+	if err != nil {
+		return false, err
+	}
+	list, _ := config["registry-mirrors"].([]interface{})
+	var kept []interface{}
+	for _, v := range list {
+		if s, ok := v.(string); !ok || !isOurs(s) {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == len(list) {
+		return false, nil
+	}
+	if len(kept) == 0 {
+		delete(config, "registry-mirrors")
+	} else {
+		config["registry-mirrors"] = kept
+	}
+	return true, writeDockerConfig(configPath, config, T("移除 DockerST 修改"))
+}
+
+func (dockerRuntime) RestartCommand() (*exec.Cmd, error) {
+	return restartCommand()
+}
+
+func (dockerRuntime) Health() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{.ServerVersion}}").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// 用于测试的容器运行时，restart 为重启命令，为空时不需要重启
+type fakeRuntime struct {
+	restart []string
+}
+
+func (fakeRuntime) Name() string                                        { return "fake" }
+func (fakeRuntime) Detect() bool                                        { return true }
+func (fakeRuntime) ConfigPath() (string, error)                         { return "fake.json", nil }
+func (fakeRuntime) SetMirror(_, _ string) error                         { return nil }
+func (fakeRuntime) PurgeMirror(string, func(string) bool) (bool, error) { return false, nil }
+
+func (rt fakeRuntime) RestartCommand() (*exec.Cmd, error) {
+	if len(rt.restart) == 0 {
+		return nil, nil
+	}
+	return exec.Command(rt.restart[0], rt.restart[1:]...), nil
+}
+
+// 重启后可以检查服务状态的容器运行时
+type fakeHealthRuntime struct {
+	fakeRuntime
+}
+
+func (fakeHealthRuntime) Health() (string, error) { return "1.0", nil }
+
+func setFakeRuntime(t *testing.T, rt Runtime) {
+	t.Helper()
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("没有 true 命令")
+	}
+	oldRuntimes, oldName, oldPolicy := Runtimes, RuntimeName, RestartPolicy
+	t.Cleanup(func() { Runtimes, RuntimeName, RestartPolicy = oldRuntimes, oldName, oldPolicy })
+	Runtimes, RuntimeName = []Runtime{rt}, rt.Name()
+}
+
+func TestRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		restart bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"ask", false}, // 安静模式或没有终端时不询问，也不重启
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			setupApplyState(t)
+			setFakeRuntime(t, fakeRuntime{restart: []string{"true"}})
+			RestartPolicy = tt.policy
+			if err := CheckRestartPolicy(); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetDockerAccelerator("https://mirror.example.com"); err != nil {
+				t.Fatalf("SetDockerAccelerator() = %v", err)
+			}
+			if got := restartedRuntime == "fake"; got != tt.restart {
+				t.Errorf("重启 = %v, want %v", got, tt.restart)
+			}
+		})
+	}
+
+	oldPolicy := RestartPolicy
+	defer func() { RestartPolicy = oldPolicy }()
+	RestartPolicy = "sometimes"
+	if err := CheckRestartPolicy(); err == nil || !strings.Contains(err.Error(), "sometimes") {
+		t.Errorf("CheckRestartPolicy(sometimes) = %v", err)
+	}
+}
+
+func TestRestartRuntime(t *testing.T) {
+	setupApplyState(t)
+	setFakeRuntime(t, fakeRuntime{})
+	if err := RestartRuntime("fake"); err != nil || restartedRuntime != "" { // 不需要重启
+		t.Errorf("RestartRuntime(不需要重启) = %v, restarted %q", err, restartedRuntime)
+	}
+
+	setFakeRuntime(t, fakeRuntime{restart: []string{"false"}})
+	if err := RestartRuntime("fake"); err == nil || restartedRuntime != "" {
+		t.Errorf("RestartRuntime(失败) = %v, restarted %q", err, restartedRuntime)
+	}
+
+	setFakeRuntime(t, fakeHealthRuntime{fakeRuntime{restart: []string{"true"}}})
+	if err := RestartRuntime("fake"); err != nil || restartedRuntime != "fake" {
+		t.Errorf("RestartRuntime() = %v, restarted %q", err, restartedRuntime)
+	}
+	if err := RestartRuntime("unknown"); err == nil {
+		t.Error("RestartRuntime(unknown) = nil, want error")
+	}
+}
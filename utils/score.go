@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSortKey = "speed"
+
+var (
+	// SortKey 最终结果的排序方式：speed（下载速度）、delay（丢包率+延迟）、loss（丢包率）、score（综合得分）
+	SortKey = defaultSortKey
+	// Weights 综合得分各项权重
+	Weights = ScoreWeights{Latency: 1, Jitter: 0.5, Loss: 1, Throughput: 1}
+)
+
+// ScoreWeights 综合得分各项权重，为 0 时不计入该项
+type ScoreWeights struct {
+	Latency    float64
+	Jitter     float64
+	Loss       float64
+	Throughput float64
+}
+
+func (w ScoreWeights) String() string {
+	return fmt.Sprintf("latency=%g,jitter=%g,loss=%g,speed=%g", w.Latency, w.Jitter, w.Loss, w.Throughput)
+}
+
+// ParseWeights 解析权重参数，格式如 latency=1,jitter=0.5,loss=1,speed=1，未指定的项保持默认值
+func ParseWeights(s string) (ScoreWeights, error) {
+	w := Weights
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return w, fmt.Errorf(T("权重格式错误：%s（应为 名称=数值）"), item)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || f < 0 {
+			return w, fmt.Errorf(T("权重数值错误：%s"), item)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "latency", "delay":
+			w.Latency = f
+		case "jitter":
+			w.Jitter = f
+		case "loss":
+			w.Loss = f
+		case "speed", "throughput":
+			w.Throughput = f
+		default:
+			return w, fmt.Errorf(T("未知的权重名称：%s（可选 latency、jitter、loss、speed）"), key)
+		}
+	}
+	if w.Latency+w.Jitter+w.Loss+w.Throughput == 0 {
+		return w, errors.New(T("权重不能全部为 0"))
+	}
+	return w, nil
+}
+
+// CheckSortKey 检查指定的排序方式是否支持
+func CheckSortKey() error {
+	switch SortKey {
+	case "", "speed", "delay", "loss", "score":
+		return nil
+	default:
+		return fmt.Errorf(T("未知的排序方式：%s（可选 speed、delay、loss、score）"), SortKey)
+	}
+}
+
+// 参与评分的下载速度及延迟，进行了稳定性测试时使用稳定性测试结果
+func (cf *CloudflareIPData) scoreSpeed() float64 {
+	if cf.StableSamples > 0 {
+		return cf.StableSpeed
+	}
+	return cf.DownloadSpeed
+}
+
+func (cf *CloudflareIPData) scoreDelay() time.Duration {
+	if cf.StableSamples > 0 {
+		return cf.StableDelay
+	}
+	return cf.Delay
+}
+
+// Rank 计算综合得分，并按指定方式排序（speed 为默认方式，保持下载测速及稳定性测试的排序）
+// 综合得分 = 各项得分按权重加权平均 × 100，每项均以本次结果中的最大值归一化：
+// 速度越快、延迟越低、抖动越低、丢包越少得分越高
+func (s DownloadSpeedSet) Rank() {
+	if len(s) == 0 {
+		return
+	}
+	var maxSpeed, maxDelay, maxJitter float64
+	for i := range s {
+		maxSpeed = max(maxSpeed, s[i].scoreSpeed())
+		maxDelay = max(maxDelay, float64(s[i].scoreDelay()))
+		maxJitter = max(maxJitter, float64(s[i].Jitter))
+	}
+	ratio := func(v, m float64) float64 {
+		if m == 0 {
+			return 0
+		}
+		return v / m
+	}
+	total := Weights.Latency + Weights.Jitter + Weights.Loss + Weights.Throughput
+	for i := range s {
+		score := Weights.Throughput*ratio(s[i].scoreSpeed(), maxSpeed) +
+			Weights.Latency*(1-ratio(float64(s[i].scoreDelay()), maxDelay)) +
+			Weights.Jitter*(1-ratio(float64(s[i].Jitter), maxJitter)) +
+			Weights.Loss*(1-float64(s[i].getLossRate()))
+		s[i].Score = score / total * 100
+	}
+
+	// 下载测速速度为 0 的 IP 无法使用，无论按哪种方式排序都排在最后
+	less := func(less func(i, j int) bool) func(i, j int) bool {
+		return func(i, j int) bool {
+			if s[i].downloadFailed() != s[j].downloadFailed() {
+				return s[j].downloadFailed()
+			}
+			return less(i, j)
+		}
+	}
+	switch SortKey {
+	case "delay":
+		sort.SliceStable(s, less(func(i, j int) bool { return PingDelaySet(s).Less(i, j) }))
+	case "loss":
+		sort.SliceStable(s, less(func(i, j int) bool { return s[i].getLossRate() < s[j].getLossRate() }))
+	case "score":
+		sort.SliceStable(s, less(func(i, j int) bool { return s[i].Score > s[j].Score }))
+	}
+}
+
+// 进行了下载测速但速度为 0，即下载失败
+func (cf *CloudflareIPData) downloadFailed() bool {
+	return cf.Trace != nil && cf.DownloadSpeed <= 0
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights(" delay=2, speed=0.5 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ScoreWeights{Latency: 2, Jitter: 0.5, Loss: 1, Throughput: 0.5}); w != want {
+		t.Errorf("ParseWeights() = %v, want %v", w, want)
+	}
+	for _, s := range []string{"latency", "latency=-1", "latency=x", "color=1", "latency=0,jitter=0,loss=0,speed=0"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("ParseWeights(%q) = nil, want error", s)
+		}
+	}
+}
+
+func testSpeedSet() DownloadSpeedSet {
+	data := func(ip string, delay, jitter time.Duration, received int, speed float64) CloudflareIPData {
+		return CloudflareIPData{
+			PingData:      &PingData{IP: &net.IPAddr{IP: net.ParseIP(ip)}, Sended: 4, Received: received, Delay: delay, Jitter: jitter},
+			DownloadSpeed: speed,
+		}
+	}
+	return DownloadSpeedSet{
+		data("1.1.1.1", 200*time.Millisecond, 20*time.Millisecond, 4, 10*1024*1024), // 最快但延迟最高
+		data("1.1.1.2", 100*time.Millisecond, 10*time.Millisecond, 2, 5*1024*1024),  // 丢包
+		data("1.1.1.3", 50*time.Millisecond, 0, 4, 8*1024*1024),
+	}
+}
+
+func TestRank(t *testing.T) {
+	oldKey, oldWeights := SortKey, Weights
+	defer func() { SortKey, Weights = oldKey, oldWeights }()
+	Weights = ScoreWeights{Latency: 1, Jitter: 0.5, Loss: 1, Throughput: 1}
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"speed", []string{"1.1.1.1", "1.1.1.2", "1.1.1.3"}}, // 保持原顺序
+		{"delay", []string{"1.1.1.3", "1.1.1.1", "1.1.1.2"}},
+		{"loss", []string{"1.1.1.1", "1.1.1.3", "1.1.1.2"}},
+		{"score", []string{"1.1.1.3", "1.1.1.1", "1.1.1.2"}},
+	}
+	for _, tt := range tests {
+		SortKey = tt.key
+		s := testSpeedSet()
+		s.Rank()
+		for i, ip := range tt.want {
+			if got := s[i].IP.String(); got != ip {
+				t.Errorf("Rank(%s)[%d] = %s, want %s", tt.key, i, got, ip)
+			}
+		}
+	}
+
+	s := testSpeedSet()
+	SortKey = "score"
+	s.Rank()
+	// 1.1.1.3：速度 0.8、延迟 0.75、抖动 1、丢包 1，按权重平均
+	if want := (0.8 + 0.75 + 0.5 + 1) / 3.5 * 100; s[0].Score < want-0.01 || s[0].Score > want+0.01 {
+		t.Errorf("Score = %.2f, want %.2f", s[0].Score, want)
+	}
+}
+
+func TestRankDownloadFailed(t *testing.T) {
+	oldKey, oldWeights := SortKey, Weights
+	defer func() { SortKey, Weights = oldKey, oldWeights }()
+	Weights = ScoreWeights{Latency: 1, Jitter: 0.5, Loss: 1, Throughput: 1}
+
+	tests := []struct {
+		key   string
+		first string
+	}{
+		{"delay", "1.1.1.3"},
+		{"loss", "1.1.1.1"},
+		{"score", "1.1.1.3"},
+	}
+	for _, tt := range tests {
+		SortKey = tt.key
+		// 1.1.1.4 延迟最低、没有丢包，但下载测速失败
+		failed := CloudflareIPData{
+			PingData: &PingData{IP: &net.IPAddr{IP: net.ParseIP("1.1.1.4")}, Sended: 4, Received: 4, Delay: 10 * time.Millisecond},
+			Trace:    &TraceData{Failure: "timeout"},
+		}
+		s := append(DownloadSpeedSet{failed}, testSpeedSet()...)
+		s.Rank()
+		if got := s[0].IP.String(); got != tt.first {
+			t.Errorf("Rank(%s)[0] = %s, want %s", tt.key, got, tt.first)
+		}
+		if got := s[len(s)-1].IP.String(); got != "1.1.1.4" {
+			t.Errorf("Rank(%s) 最后一个 = %s, want 1.1.1.4", tt.key, got)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// StateDir 返回保存历史记录、备份等数据的目录（不存在时自动创建）
+// Linux/macOS：$XDG_STATE_HOME/dockerst 或 ~/.local/state/dockerst，Windows：%LOCALAPPDATA%\DockerST
+func StateDir() (string, error) {
+	var dir string
+	if runtime.GOOS == "windows" {
+		base := os.Getenv("LOCALAPPDATA")
+		if base == "" {
+			var err error
+			if base, err = os.UserConfigDir(); err != nil {
+				return "", err
+			}
+		}
+		dir = filepath.Join(base, "DockerST")
+	} else if base := os.Getenv("XDG_STATE_HOME"); base != "" {
+		dir = filepath.Join(base, "dockerst")
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state", "dockerst")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
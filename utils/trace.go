@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// TraceOutput 各阶段耗时诊断结果输出文件，为空则不输出
+	TraceOutput string
+
+	traceMu      sync.Mutex
+	traceRecords []TraceRecord
+)
+
+// TraceData 单次 HTTP 请求各阶段耗时及失败原因
+type TraceData struct {
+	Connect  time.Duration // TCP 连接
+	TLS      time.Duration // TLS 握手
+	TTFB     time.Duration // 发出请求到收到首字节
+	Transfer time.Duration // 首字节到读取结束
+	Failure  string        // 失败原因：refused、reset、timeout、cert、status xxx、colo、error，成功为空
+}
+
+// TraceRecord 某个 IP 在某个测速阶段的请求记录
+type TraceRecord struct {
+	IP    string
+	Stage string // httping、download
+	*TraceData
+}
+
+// AddTrace 记录 IP 的请求各阶段耗时（包括失败的 IP）
+func AddTrace(ip, stage string, data *TraceData) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	traceRecords = append(traceRecords, TraceRecord{IP: ip, Stage: stage, TraceData: data})
+}
+
+// Traces 返回已记录的所有请求记录
+func Traces() []TraceRecord {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	return append([]TraceRecord(nil), traceRecords...)
+}
+
+func durationMs(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds()*1000, 'f', 2, 64)
+}
+
+// ExportTrace 输出各阶段耗时诊断结果，创建或写入失败时返回错误
+func ExportTrace() error {
+	records := Traces()
+	if TraceOutput == "" || len(records) == 0 {
+		return nil
+	}
+	fp, err := os.Create(TraceOutput)
+	if err != nil {
+		return fmt.Errorf(T("创建文件[%s]失败：%v"), TraceOutput, err)
+	}
+	defer fp.Close()
+	w := csv.NewWriter(fp)
+	_ = w.Write([]string{"ip", "stage", "connect_ms", "tls_ms", "ttfb_ms", "transfer_ms", "failure"})
+	for _, r := range records {
+		_ = w.Write([]string{r.IP, r.Stage, durationMs(r.Connect), durationMs(r.TLS), durationMs(r.TTFB), durationMs(r.Transfer), r.Failure})
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return fmt.Errorf(T("写入文件[%s]失败：%v"), TraceOutput, err)
+	}
+	return nil
+}
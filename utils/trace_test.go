@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportTrace(t *testing.T) {
+	oldOutput, oldRecords := TraceOutput, traceRecords
+	t.Cleanup(func() { TraceOutput, traceRecords = oldOutput, oldRecords })
+	traceRecords = nil
+	AddTrace("1.1.1.1", "download", &TraceData{Connect: 10 * time.Millisecond, TLS: 20 * time.Millisecond, TTFB: 50 * time.Millisecond, Transfer: time.Second})
+	AddTrace("1.1.1.2", "httping", &TraceData{Failure: "timeout"})
+
+	TraceOutput = filepath.Join(t.TempDir(), "trace.csv")
+	if err := ExportTrace(); err != nil {
+		t.Fatalf("ExportTrace() = %v", err)
+	}
+	content, err := os.ReadFile(TraceOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ip,stage,connect_ms,tls_ms,ttfb_ms,transfer_ms,failure\n" +
+		"1.1.1.1,download,10.00,20.00,50.00,1000.00,\n" +
+		"1.1.1.2,httping,0.00,0.00,0.00,0.00,timeout\n"
+	if string(content) != want {
+		t.Errorf("trace.csv = %q, want %q", content, want)
+	}
+
+	// 无法创建文件时返回错误，由调用方提示后继续运行
+	TraceOutput = filepath.Join(t.TempDir(), "missing", "trace.csv")
+	if err := ExportTrace(); err == nil || !strings.Contains(err.Error(), TraceOutput) {
+		t.Errorf("ExportTrace() = %v, want error", err)
+	}
+}
@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 获取清单时接受的格式，hello-world 等官方镜像为多架构的清单列表
+var manifestAccept = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+var (
+	// NoVerify 写入后不验证修改是否生效
+	NoVerify bool
+	// VerifyTimeout 验证的最长时间，超时仍未通过时自动还原本次修改
+	VerifyTimeout = 30 * time.Second
+	// VerifyImage 验证时通过加速器获取清单的镜像
+	VerifyImage = "library/hello-world"
+
+	// ErrVerifyFailed 修改后验证未通过（已自动还原）
+	ErrVerifyFailed error = verifyFailedError{}
+
+	// 本次运行中重启过的容器运行时
+	restartedRuntime string
+)
+
+// 错误信息在输出时才翻译，包初始化时尚未确定语言
+type verifyFailedError struct{}
+
+func (verifyFailedError) Error() string {
+	return T("验证未通过，已还原本次修改")
+}
+
+// VerifyApplied 验证写入的 hosts 及加速器是否生效：Docker 已加载加速器（重启过 Docker 时）、
+// 目标域名解析为写入的 IP、能通过加速器获取镜像清单，每项检查失败时重试直到超时
+func VerifyApplied(dockerUrl string) error {
+	Println(T("\n[信息] 开始验证修改是否生效..."))
+	ctx, cancel := context.WithTimeout(context.Background(), VerifyTimeout)
+	defer cancel()
+
+	type check struct {
+		name string
+		run  func(ctx context.Context) error
+	}
+	var checks []check
+	if restartedRuntime == BackupDocker { // 没有重启时 Docker 还在使用原来的配置
+		checks = append(checks, check{T("Docker 已加载加速器"), func(ctx context.Context) error {
+			return checkDockerMirror(ctx, dockerUrl)
+		}})
+	}
+	if AppliedIP != "" {
+		entries := append([]HostIP{{Host: DockerDomain(), IP: AppliedIP}}, HostIPs...)
+		for _, h := range entries {
+			checks = append(checks, check{fmt.Sprintf(T("%s 解析为 %s"), h.Host, h.IP), func(ctx context.Context) error {
+				return checkResolve(ctx, h.Host, h.IP)
+			}})
+		}
+	}
+	checks = append(checks, check{fmt.Sprintf(T("通过加速器获取 %s 的清单"), VerifyImage), func(ctx context.Context) error {
+		return fetchManifest(ctx, dockerUrl, VerifyImage, "latest")
+	}})
+
+	for _, c := range checks {
+		var lastErr error
+		for {
+			err := c.run(ctx)
+			if err == nil {
+				Printf("  [OK] %s\n", c.name)
+				break
+			}
+			if ctx.Err() == nil || lastErr == nil { // 超时中断的请求不如上一次的错误有用
+				lastErr = err
+			}
+			if ctx.Err() != nil {
+				Printf("  [FAIL] %s: %v\n", c.name, lastErr)
+				return fmt.Errorf("%s: %w", c.name, lastErr)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+	return nil
+}
+
+// RollbackApplied 将本次运行修改的文件还原为修改前的内容，重启过的容器运行时会再次重启
+func RollbackApplied() error {
+	if backupID == "" {
+		return nil
+	}
+	restored, err := RestoreBackup(backupID)
+	for _, r := range restored {
+		Printf(T("[信息] 已还原 %s。\n"), r.Path)
+	}
+	if err != nil {
+		return err
+	}
+	AppliedIP, appliedData = "", nil
+	if name := restartedRuntime; name != "" {
+		return RestartRuntime(name)
+	}
+	return nil
+}
+
+// 通过 Engine API 读取 Docker 当前使用的 registry-mirrors，检查其中是否有加速器地址
+func checkDockerMirror(ctx context.Context, dockerUrl string) error {
+	mirrors, err := dockerMirrors(ctx)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(mirrors, func(m string) bool { return sameMirror(m, dockerUrl) }) {
+		return fmt.Errorf(T("Docker 的 registry-mirrors 中没有 %s：%v"), dockerUrl, mirrors)
+	}
+	return nil
+}
+
+func dockerMirrors(ctx context.Context) ([]string, error) {
+	var info struct {
+		RegistryConfig struct {
+			Mirrors []string
+		}
+	}
+	socket := dockerSocket()
+	if socket == "" { // 没有 Unix socket 时（Windows、DOCKER_HOST 为 tcp:// 等）通过 docker 命令读取
+		out, err := exec.CommandContext(ctx, "docker", "info", "--format", "{{json .}}").Output()
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(out, &info)
+		return info.RegistryConfig.Mirrors, err
+	}
+	hc := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	requ, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/info", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := hc.Do(requ)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Engine API: %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	return info.RegistryConfig.Mirrors, err
+}
+
+// 返回 Docker Engine API 的 Unix socket 路径：DOCKER_HOST、rootless socket 或 /var/run/docker.sock，不是 Unix socket 时返回空
+func dockerSocket() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		path, _ := strings.CutPrefix(host, "unix://")
+		if path == host {
+			return ""
+		}
+		return path
+	}
+	if isRootlessDocker() {
+		return rootlessDockerSocket()
+	}
+	return "/var/run/docker.sock"
+}
+
+// 检查域名是否解析为写入 hosts 的 IP
+func checkResolve(ctx context.Context, host, ip string) error {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(addrs, ip) {
+		return fmt.Errorf(T("解析结果为 %v"), addrs)
+	}
+	return nil
+}
+
+// 通过加速器获取镜像清单（Registry API v2），需要认证时先匿名获取 token
+func fetchManifest(ctx context.Context, dockerUrl, image, tag string) error {
+	hc := http.Client{Timeout: 10 * time.Second}
+	manifestUrl := strings.TrimRight(dockerUrl, "/") + "/v2/" + image + "/manifests/" + tag
+	var token string
+	for i := 0; i < 2; i++ {
+		requ, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestUrl, nil)
+		if err != nil {
+			return err
+		}
+		requ.Header.Set("Accept", manifestAccept)
+		if token != "" {
+			requ.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := hc.Do(requ)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return nil
+		case resp.StatusCode == http.StatusUnauthorized && token == "":
+			if token, err = registryToken(ctx, &hc, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s: %s", manifestUrl, resp.Status)
+		}
+	}
+	return fmt.Errorf(T("%s: 认证失败"), manifestUrl)
+}
+
+// 按 WWW-Authenticate: Bearer realm="...",service="...",scope="..." 匿名获取 token
+func registryToken(ctx context.Context, hc *http.Client, challenge string) (string, error) {
+	params, ok := strings.CutPrefix(challenge, "Bearer ")
+	if !ok {
+		return "", fmt.Errorf(T("不支持的认证方式：%s"), challenge)
+	}
+	query := url.Values{}
+	var realm string
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		if key == "realm" {
+			realm = value
+		} else {
+			query.Set(key, value)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf(T("不支持的认证方式：%s"), challenge)
+	}
+	requ, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := hc.Do(requ)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", realm, resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token == "" {
+		body.Token = body.AccessToken
+	}
+	if body.Token == "" {
+		return "", errors.New(T("未获取到 token"))
+	}
+	return body.Token, nil
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "test-token"
+
+// 使用临时目录保存备份记录，并重置本次运行的状态
+func setupApplyState(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	oldQuiet, oldTimeout := Quiet, VerifyTimeout
+	Quiet, VerifyTimeout = true, 2*time.Second
+	t.Cleanup(func() {
+		Quiet, VerifyTimeout = oldQuiet, oldTimeout
+		backupID, restartedRuntime, AppliedIP, appliedData, modifiedFiles = "", "", "", nil, make(map[string]bool)
+	})
+	backupID, restartedRuntime, AppliedIP, appliedData, modifiedFiles = "", "", "", nil, make(map[string]bool)
+}
+
+// 在 Unix socket 上模拟 Docker Engine API 的 /info，并通过 DOCKER_HOST 指向它
+func startDockerAPI(t *testing.T, mirrors ...string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上通过 docker 命令读取 Docker 信息")
+	}
+	// Unix socket 路径长度有限，不使用 t.TempDir() 的长路径
+	dir, err := os.MkdirTemp("", "dockerst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("无法监听 Unix socket: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/info" {
+			http.NotFound(w, r)
+			return
+		}
+		var info struct {
+			RegistryConfig struct {
+				Mirrors []string
+			}
+		}
+		info.RegistryConfig.Mirrors = mirrors
+		_ = json.NewEncoder(w).Encode(info)
+	}))
+	srv.Listener = listener
+	srv.Start()
+	t.Cleanup(srv.Close)
+	t.Setenv("DOCKER_HOST", "unix://"+socket)
+}
+
+// 模拟需要匿名 token 的镜像仓库，manifestStatus 为带 token 请求清单时的响应状态
+func startRegistry(t *testing.T, manifestStatus int) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:library/hello-world:pull" {
+				http.Error(w, "bad scope", http.StatusBadRequest)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]string{"access_token": testToken})
+		case r.URL.Path == "/v2/library/hello-world/manifests/latest":
+			if r.Header.Get("Authorization") != "Bearer "+testToken {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="registry",scope="repository:library/hello-world:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !strings.Contains(r.Header.Get("Accept"), "manifest.list.v2+json") {
+				http.Error(w, "bad accept", http.StatusNotAcceptable)
+				return
+			}
+			w.WriteHeader(manifestStatus)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerifyApplied(t *testing.T) {
+	setupApplyState(t)
+	registry := startRegistry(t, http.StatusOK)
+	startDockerAPI(t, "https://other.example.com", registry.URL+"/")
+	restartedRuntime = BackupDocker
+
+	if err := VerifyApplied(registry.URL); err != nil {
+		t.Fatalf("VerifyApplied() = %v", err)
+	}
+}
+
+func TestVerifyAppliedMirrorNotLoaded(t *testing.T) {
+	setupApplyState(t)
+	registry := startRegistry(t, http.StatusOK)
+	startDockerAPI(t, "https://other.example.com")
+	restartedRuntime = BackupDocker
+
+	err := VerifyApplied(registry.URL)
+	if err == nil || !strings.Contains(err.Error(), "https://other.example.com") {
+		t.Fatalf("VerifyApplied() = %v, want registry-mirrors error", err)
+	}
+}
+
+func TestVerifyAppliedManifestFailed(t *testing.T) {
+	setupApplyState(t)
+	registry := startRegistry(t, http.StatusNotFound)
+
+	err := VerifyApplied(registry.URL)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("VerifyApplied() = %v, want 404", err)
+	}
+}
+
+func TestRollbackApplied(t *testing.T) {
+	setupApplyState(t)
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "daemon.json")
+	created := filepath.Join(dir, "hosts.toml")
+	if err := os.WriteFile(existing, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := startRegistry(t, http.StatusInternalServerError)
+	if err := applyFile(BackupDocker, existing, registry.URL, []byte(`{"registry-mirrors": ["`+registry.URL+`"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyFile(BackupContainerd, created, registry.URL, []byte("server = \"https://registry-1.docker.io\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyApplied(registry.URL); err == nil {
+		t.Fatal("VerifyApplied() = nil, want error")
+	}
+	if err := RollbackApplied(); err != nil {
+		t.Fatalf("RollbackApplied() = %v", err)
+	}
+	if content, err := os.ReadFile(existing); err != nil || string(content) != "{}\n" {
+		t.Errorf("%s = %q, %v, want restored", existing, content, err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("%s 应被删除: %v", created, err)
+	}
+
+	// 同一次运行中每个文件只备份一次，还原不会覆盖修改前的备份
+	records, err := LoadBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != backupID {
+		t.Errorf("备份记录 = %+v", records)
+	}
+}